@@ -1,4 +1,4 @@
-package main
+package monkey
 
 import (
 	"bytes"
@@ -117,6 +117,26 @@ func (l *LetStatement) toString() string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type ConstStatement struct {
+	token Token
+	name  *Identifier
+	value Expression
+}
+
+func (c *ConstStatement) statementNode() {}
+
+func (c *ConstStatement) tokenLiteral() string { return c.token.literal }
+
+func (c *ConstStatement) toString() string {
+	if c.value != nil {
+		return fmt.Sprintf("%v %v = %v", c.tokenLiteral(), c.name.toString(), c.value.toString())
+	}
+
+	return fmt.Sprintf("%v %v = null", c.tokenLiteral(), c.name.toString())
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type ReturnStatement struct {
 	token Token
 	value Expression
@@ -224,9 +244,44 @@ func (f *FloatLiteral) toString() string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type DoWhileExpression struct {
+	token     Token
+	body      *BlockStatement
+	condition Expression
+}
+
+func (d *DoWhileExpression) expressionNode() {}
+
+func (d *DoWhileExpression) tokenLiteral() string { return d.token.literal }
+
+func (d *DoWhileExpression) toString() string {
+	return fmt.Sprintf("do %v while %v", d.body.toString(), d.condition.toString())
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+type ForInExpression struct {
+	token    Token
+	variable *Identifier
+	iterable Expression
+	body     *BlockStatement
+}
+
+func (f *ForInExpression) expressionNode() {}
+
+func (f *ForInExpression) tokenLiteral() string { return f.token.literal }
+
+func (f *ForInExpression) toString() string {
+	return fmt.Sprintf("for (%v in %v) %v", f.variable.toString(), f.iterable.toString(), f.body.toString())
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type FunctionLiteral struct {
 	token      Token
 	parameters []*Identifier
+	defaults   []Expression
+	rest       *Identifier
 	body       *BlockStatement
 }
 
@@ -238,8 +293,15 @@ func (f *FunctionLiteral) toString() string {
 	var buffer bytes.Buffer
 	params := make([]string, 0)
 
-	for _, param := range f.parameters {
-		params = append(params, param.toString())
+	for idx, param := range f.parameters {
+		if f.defaults[idx] != nil {
+			params = append(params, fmt.Sprintf("%v = %v", param.toString(), f.defaults[idx].toString()))
+		} else {
+			params = append(params, param.toString())
+		}
+	}
+	if f.rest != nil {
+		params = append(params, "..."+f.rest.toString())
 	}
 
 	buffer.WriteString(f.tokenLiteral())
@@ -256,6 +318,7 @@ func (f *FunctionLiteral) toString() string {
 type HashLiteral struct {
 	token Token
 	pairs map[Expression]Expression
+	keys  []Expression
 }
 
 func (h *HashLiteral) expressionNode() {}
@@ -266,8 +329,8 @@ func (h *HashLiteral) toString() string {
 	var buffer bytes.Buffer
 	args := make([]string, 0)
 
-	for key, value := range h.pairs {
-		args = append(args, key.toString()+":"+value.toString())
+	for _, key := range h.keys {
+		args = append(args, key.toString()+":"+h.pairs[key].toString())
 	}
 
 	buffer.WriteString("{")
@@ -305,7 +368,7 @@ func (i *IndexExpression) expressionNode() {}
 func (i *IndexExpression) tokenLiteral() string { return i.token.literal }
 
 func (i *IndexExpression) toString() string {
-	return fmt.Sprintf("(%v[%v])", i.left.toString(), i.index.toString())
+	return fmt.Sprintf("(%v%v%v])", i.left.toString(), i.token.literal, i.index.toString())
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -352,6 +415,21 @@ func (i *InfixExpression) toString() string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type CharLiteral struct {
+	token Token
+	value rune
+}
+
+func (c *CharLiteral) expressionNode() {}
+
+func (c *CharLiteral) tokenLiteral() string { return c.token.literal }
+
+func (c *CharLiteral) toString() string {
+	return fmt.Sprintf("'%v'", c.token.literal)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type IntegerLiteral struct {
 	token Token
 	value int64
@@ -367,6 +445,22 @@ func (i *IntegerLiteral) toString() string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type MemberExpression struct {
+	token  Token
+	object Expression
+	member *Identifier
+}
+
+func (m *MemberExpression) expressionNode() {}
+
+func (m *MemberExpression) tokenLiteral() string { return m.token.literal }
+
+func (m *MemberExpression) toString() string {
+	return fmt.Sprintf("(%v%v%v)", m.object.toString(), m.token.literal, m.member.toString())
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type StringLiteral struct {
 	token Token
 	value string
@@ -380,6 +474,22 @@ func (s *StringLiteral) toString() string { return s.token.literal }
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type PostfixExpression struct {
+	token    Token
+	operator string
+	left     *Identifier
+}
+
+func (p *PostfixExpression) expressionNode() {}
+
+func (p *PostfixExpression) tokenLiteral() string { return p.token.literal }
+
+func (p *PostfixExpression) toString() string {
+	return fmt.Sprintf("(%v%v)", p.left.toString(), p.operator)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type PrefixExpression struct {
 	token    Token
 	operator string
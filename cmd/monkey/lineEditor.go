@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	HISTORY_FILE = ".monkey_history"
+	MAX_HISTORY  = 1000
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// --------------------------------------------------------------------------------------------------------------------
+// Line editing and history for the REPL
+// --------------------------------------------------------------------------------------------------------------------
+
+type LineEditor struct {
+	reader      *bufio.Reader
+	history     []string
+	historyPath string
+	interactive bool
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func newLineEditor() *LineEditor {
+	editor := &LineEditor{
+		reader:      bufio.NewReader(os.Stdin),
+		history:     make([]string, 0),
+		interactive: isTerminal(int(os.Stdin.Fd())),
+	}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		editor.historyPath = filepath.Join(home, HISTORY_FILE)
+		editor.loadHistory()
+	}
+
+	return editor
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *LineEditor) readLine(prompt string) (string, error) {
+	fmt.Print(prompt)
+
+	if !e.interactive {
+		line, err := e.reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		e.interactive = false
+		return e.readLine("")
+	}
+	line, err := e.edit(prompt)
+	restore()
+	fmt.Println()
+
+	if err != nil {
+		return "", err
+	}
+
+	line = strings.TrimSpace(line)
+	e.addHistory(line)
+
+	return line, nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Editing
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *LineEditor) edit(prompt string) (string, error) {
+	buffer := make([]rune, 0)
+	cursor := 0
+	// Browsing starts one past the newest entry, which stands for the line being typed.
+	historyIdx := len(e.history)
+	draft := ""
+
+	for {
+		ch, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch ch {
+		case '\r', '\n':
+			return string(buffer), nil
+		case 3: // Ctrl-C
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buffer) == 0 {
+				return "", io.EOF
+			}
+		case 127, 8: // Backspace
+			if cursor > 0 {
+				buffer = append(buffer[:cursor-1], buffer[cursor:]...)
+				cursor -= 1
+			}
+		case 1: // Ctrl-A
+			cursor = 0
+		case 5: // Ctrl-E
+			cursor = len(buffer)
+		case 27: // Escape sequences for the arrow, home, end and delete keys
+			final, params := e.readEscape()
+			switch final {
+			case 'A':
+				if historyIdx > 0 {
+					if historyIdx == len(e.history) {
+						draft = string(buffer)
+					}
+					historyIdx -= 1
+					buffer = []rune(e.history[historyIdx])
+					cursor = len(buffer)
+				}
+			case 'B':
+				if historyIdx < len(e.history) {
+					historyIdx += 1
+					if historyIdx == len(e.history) {
+						buffer = []rune(draft)
+					} else {
+						buffer = []rune(e.history[historyIdx])
+					}
+					cursor = len(buffer)
+				}
+			case 'C':
+				cursor = min(cursor+1, len(buffer))
+			case 'D':
+				cursor = max(cursor-1, 0)
+			case 'H':
+				cursor = 0
+			case 'F':
+				cursor = len(buffer)
+			case '~':
+				switch params {
+				case "1", "7":
+					cursor = 0
+				case "4", "8":
+					cursor = len(buffer)
+				case "3":
+					if cursor < len(buffer) {
+						buffer = append(buffer[:cursor], buffer[cursor+1:]...)
+					}
+				}
+			}
+		default:
+			if ch >= ' ' {
+				buffer = append(buffer[:cursor], append([]rune{ch}, buffer[cursor:]...)...)
+				cursor += 1
+			}
+		}
+
+		e.redraw(prompt, buffer, cursor)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Reads the rest of an escape sequence like "[A" or "[3~". Returns its final byte and any parameter bytes before it,
+// or 0 if it isn't a sequence we handle.
+func (e *LineEditor) readEscape() (rune, string) {
+	next, _, err := e.reader.ReadRune()
+	if err != nil || (next != '[' && next != 'O') {
+		return 0, ""
+	}
+
+	params := make([]rune, 0)
+	for {
+		code, _, err := e.reader.ReadRune()
+		if err != nil {
+			return 0, ""
+		}
+		if 0x40 <= code && code <= 0x7E {
+			return code, string(params)
+		}
+		params = append(params, code)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *LineEditor) redraw(prompt string, buffer []rune, cursor int) {
+	fmt.Printf("\r%v%v\x1b[K", prompt, string(buffer))
+
+	if back := len(buffer) - cursor; back > 0 {
+		fmt.Printf("\x1b[%vD", back)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// History
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *LineEditor) loadHistory() {
+	data, err := os.ReadFile(e.historyPath)
+	if err != nil {
+		return
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[len(e.history)-MAX_HISTORY:]
+		os.WriteFile(e.historyPath, []byte(strings.Join(e.history, "\n")+"\n"), 0o600)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *LineEditor) addHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MAX_HISTORY {
+		e.history = e.history[1:]
+	}
+
+	if e.historyPath == "" {
+		return
+	}
+	file, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, line)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"monkey"
+	"os"
+)
+
+func main() {
+	if len(os.Args) == 3 && os.Args[1] == "fmt" {
+		formatFile(os.Args[2])
+		return
+	}
+
+	allowIO := flag.Bool("allow-io", false, "allow builtins that touch the file system")
+	source := flag.String("e", "", "evaluate the given source and exit")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: monkey [-allow-io] [-e source | file]")
+		fmt.Fprintln(os.Stderr, "       monkey fmt file")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	interpreter := monkey.New()
+	interpreter.EnableFileIO(*allowIO)
+
+	// -e beats a file argument, which beats piped stdin, which beats the REPL.
+	if *source != "" {
+		os.Exit(runProgram(interpreter, *source))
+	}
+	if flag.NArg() > 0 {
+		source, err := os.ReadFile(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(runProgram(interpreter, string(source)))
+	}
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal(err)
+		}
+		os.Exit(runProgram(interpreter, string(source)))
+	}
+
+	history := &resultHistory{}
+	editor := newLineEditor()
+
+	for {
+		input, err := editor.readLine("> ")
+		if err == errInterrupted {
+			continue
+		}
+		if err == io.EOF || input == "quit" {
+			return
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		out := interpreter.Output()
+		evaluated, err := interpreter.Run(input)
+		var exitErr *monkey.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+		if parseErr, ok := err.(*monkey.ParseError); ok {
+			for _, msg := range parseErr.Errors() {
+				fmt.Fprintln(out, msg)
+			}
+		} else if evaluated != nil {
+			fmt.Fprintln(out, evaluated.Inspect())
+			if err == nil {
+				history.record(interpreter.Env(), evaluated)
+			}
+		}
+		fmt.Fprintln(out, "")
+	}
+}
+
+// Runs source as a whole program, reporting errors on stderr. Returns the exit code.
+func runProgram(interpreter *monkey.Interpreter, source string) int {
+	_, err := interpreter.Run(source)
+	var exitErr *monkey.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	if parseErr, ok := err.(*monkey.ParseError); ok {
+		for _, msg := range parseErr.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+// Binds REPL results to _1, _2, ... and the latest one to _. Errors and null are not recorded, and once the user
+// binds _ themselves the REPL stops overwriting it.
+type resultHistory struct {
+	count int
+	last  monkey.Object
+}
+
+func (h *resultHistory) record(env *monkey.Environment, result monkey.Object) {
+	if result.Type() == monkey.NULL_OBJ {
+		return
+	}
+
+	h.count += 1
+	env.Set(fmt.Sprintf("_%v", h.count), result)
+
+	if current, ok := env.Get("_"); ok && current != h.last {
+		return
+	}
+	env.Set("_", result)
+	h.last = result
+}
+
+func formatFile(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	formatted, err := monkey.Format(string(source))
+	if parseErr, ok := err.(*monkey.ParseError); ok {
+		for _, msg := range parseErr.Errors() {
+			fmt.Fprintln(os.Stderr, msg)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Println(formatted)
+}
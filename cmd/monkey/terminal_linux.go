@@ -0,0 +1,50 @@
+//go:build linux
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Raw terminal mode
+// --------------------------------------------------------------------------------------------------------------------
+
+func makeRaw(fd int) (func(), error) {
+	var original syscall.Termios
+	if err := ioctlTermios(fd, syscall.TCGETS, &original); err != nil {
+		return nil, err
+	}
+
+	raw := original
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.BRKINT | syscall.INPCK | syscall.ISTRIP
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctlTermios(fd, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return func() { ioctlTermios(fd, syscall.TCSETS, &original) }, nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctlTermios(fd, syscall.TCGETS, &termios) == nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func ioctlTermios(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
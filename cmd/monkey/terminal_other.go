@@ -0,0 +1,22 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// --------------------------------------------------------------------------------------------------------------------
+// Raw terminal mode
+// --------------------------------------------------------------------------------------------------------------------
+
+// Line editing is only supported on linux. Elsewhere the REPL falls back to plain buffered input.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminal mode not supported on this platform")
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+// --------------------------------------------------------------------------------------------------------------------
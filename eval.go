@@ -1,11 +1,39 @@
-package main
+package monkey
 
-import "fmt"
+import (
+	"fmt"
+	"slices"
+)
 
 var FalseObject = Boolean{value: false}
 var TrueObject = Boolean{value: true}
 var NullObject = Null{}
 
+const MAX_OVERLOAD_DEPTH = 100
+
+const DEFAULT_MAX_CALL_DEPTH = 50000
+
+var overloadMethods = map[string]string{
+	"+":  "__add__",
+	"-":  "__sub__",
+	"*":  "__mul__",
+	"/":  "__div__",
+	"==": "__eq__",
+}
+
+// Builtins callable as methods, value.name(args) being sugar for name(value, args).
+var methodTable = map[ObjectType][]string{
+	STRING_OBJ: {
+		"len", "upper", "lower", "trim", "capitalize", "title", "replace", "count_substr", "chars", "rune_len",
+		"chunk_string", "repeat", "to_camel", "to_snake", "to_kebab", "squeeze_spaces", "edit_distance",
+	},
+	ARRAY_OBJ: {
+		"len", "first", "last", "rest", "push", "map", "filter", "reduce", "reduce_while", "each", "concat", "slice",
+		"repeat", "clone", "argmin", "argmax", "union", "intersection", "difference", "homogeneous",
+	},
+	HASH_OBJ: {"len", "keys", "values", "has_key", "merge", "invert", "with_defaults", "clone", "freeze"},
+}
+
 // --------------------------------------------------------------------------------------------------------------------
 // Evaluate parsed ast nodes
 // --------------------------------------------------------------------------------------------------------------------
@@ -20,11 +48,24 @@ func eval(node Node, env *Environment) Object {
 	case *ExpressionStatement:
 		return eval(node.expression, env)
 	case *LetStatement:
+		if env.consts[node.name.value] {
+			return constantReassignmentError(node.token, node.name.value)
+		}
+		val := eval(node.value, env)
+		if isError(val) {
+			return val
+		}
+		env.Set(node.name.value, val)
+		return val
+	case *ConstStatement:
+		if env.consts[node.name.value] {
+			return constantReassignmentError(node.token, node.name.value)
+		}
 		val := eval(node.value, env)
 		if isError(val) {
 			return val
 		}
-		env.set(node.name.value, val)
+		env.setConst(node.name.value, val)
 		return val
 	case *ReturnStatement:
 		val := eval(node.value, env)
@@ -47,17 +88,27 @@ func eval(node Node, env *Environment) Object {
 		if isError(function) {
 			return function
 		}
+		// obj?.method() on a NULL receiver skips the call as well.
+		if member, ok := node.function.(*MemberExpression); ok && member.token.tokenType == OPTIONAL_DOT && function.Type() == NULL_OBJ {
+			return &NullObject
+		}
 		args := evalExpressions(node.arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(node.token, function, args)
+		return applyFunction(node.token, function, args, env)
 	case *FloatLiteral:
 		return &Float{value: node.value}
+	case *DoWhileExpression:
+		return evalDoWhileExpression(node, env)
+	case *ForInExpression:
+		return evalForInExpression(node, env)
 	case *FunctionLiteral:
 		params := node.parameters
+		defaults := node.defaults
+		rest := node.rest
 		body := node.body
-		return &Function{parameters: params, env: env, body: body}
+		return &Function{parameters: params, defaults: defaults, rest: rest, env: env, body: body}
 	case *HashLiteral:
 		return evalHashLiteral(node.token, node, env)
 	case *Identifier:
@@ -69,24 +120,48 @@ func eval(node Node, env *Environment) Object {
 		if isError(left) {
 			return left
 		}
+		if node.token.tokenType == OPTIONAL_LBRACKET && left.Type() == NULL_OBJ {
+			return &NullObject
+		}
 		index := eval(node.index, env)
 		if isError(index) {
 			return index
 		}
 
-		return evalIndexExpression(node.token, left, index)
+		return evalIndexExpression(node.token, left, index, env)
 	case *IntegerLiteral:
 		return &Integer{value: node.value}
+	case *CharLiteral:
+		// Characters have no object of their own, they evaluate to their code point.
+		return &Integer{value: int64(node.value)}
+	case *MemberExpression:
+		object := eval(node.object, env)
+		if isError(object) {
+			return object
+		}
+		if node.token.tokenType == OPTIONAL_DOT && object.Type() == NULL_OBJ {
+			return &NullObject
+		}
+		return evalMemberExpression(node.token, object, node.member.value)
 	case *InfixExpression:
 		left := eval(node.left, env)
 		if isError(left) {
 			return left
 		}
+		// The right side of ?? is only evaluated when it is needed.
+		if node.token.tokenType == COALESCE {
+			if left.Type() != NULL_OBJ {
+				return left
+			}
+			return eval(node.right, env)
+		}
 		right := eval(node.right, env)
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpr(node.token, left, right, node.operator)
+		return evalInfixExpr(node.token, left, right, node.operator, env)
+	case *PostfixExpression:
+		return evalPostfixExpression(node, env)
 	case *PrefixExpression:
 		right := eval(node.right, env)
 		if isError(right) {
@@ -111,7 +186,7 @@ func evalProgram(program *Program, env *Environment) Object {
 		result = eval(stmt, env)
 
 		switch result := result.(type) {
-		case *ReturnValue, *Error:
+		case *ReturnValue, *Error, *Exit:
 			return result
 		}
 	}
@@ -129,7 +204,7 @@ func evalBlockStatement(block *BlockStatement, env *Environment) Object {
 
 		if result != nil {
 			returnType := result.Type()
-			if returnType == RETURN_OBJ || returnType == ERR_OBJ {
+			if returnType == RETURN_OBJ || isError(result) {
 				return result
 			}
 		}
@@ -157,9 +232,9 @@ func evalExpressions(exprs []Expression, env *Environment) []Object {
 // --------------------------------------------------------------------------------------------------------------------
 
 func evalHashLiteral(token Token, node *HashLiteral, env *Environment) Object {
-	pairs := make(map[HashKey]HashPair)
+	hash := newHash()
 
-	for keyNode, valueNode := range node.pairs {
+	for _, keyNode := range node.keys {
 		key := eval(keyNode, env)
 		if isError(key) {
 			return key
@@ -167,30 +242,41 @@ func evalHashLiteral(token Token, node *HashLiteral, env *Environment) Object {
 
 		hashKey, ok := key.(Hashable)
 		if !ok {
-			return newError("unusable as hash key: %v. On line: %v, column: %v.", key.Type(), token.line, token.column)
+			return newPositionedError(token, "unusable as hash key: %v.", key.Type())
 		}
 
-		value := eval(valueNode, env)
+		value := eval(node.pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
 
-		hashed := hashKey.HashKey()
-		pairs[hashed] = HashPair{key: key, value: value}
+		hash.set(hashKey.HashKey(), HashPair{key: key, value: value})
 	}
 
-	return &Hash{pairs: pairs}
+	return hash
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func evalArrayIndexExpression(array, index Object) Object {
-	arrayObject := array.(*Array)
-	idx := index.(*Integer).value
-	max := int64(len(arrayObject.elements) - 1)
+func evalArrayIndexExpression(token Token, array, index Object) Object {
+	arrayObject, ok := array.(*Array)
+	if !ok {
+		return newPositionedError(token, "index operator not supported: %v.", array.Type())
+	}
+	integer, ok := index.(*Integer)
+	if !ok {
+		return newPositionedError(token, "array index must be an INTEGER, got %v.", index.Type())
+	}
 
-	if idx < 0 || idx > max {
-		return &NullObject
+	idx := integer.value
+	length := int64(len(arrayObject.elements))
+
+	// Negative indices count back from the end, so -1 is the last element.
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return newPositionedError(token, "index %v out of range [0, %v).", integer.value, length)
 	}
 
 	return arrayObject.elements[idx]
@@ -198,14 +284,55 @@ func evalArrayIndexExpression(array, index Object) Object {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func evalHashIndexExpression(token Token, hash, index Object) Object {
-	hashObject := hash.(*Hash)
+// Strings are indexed by character rather than byte, and follow the same negative index rules as arrays.
+func evalStringIndexExpression(token Token, str, index Object) Object {
+	stringObject, ok := str.(*StringValue)
+	if !ok {
+		return newPositionedError(token, "index operator not supported: %v.", str.Type())
+	}
+	integer, ok := index.(*Integer)
+	if !ok {
+		return newPositionedError(token, "string index must be an INTEGER, got %v.", index.Type())
+	}
+
+	chars := []rune(stringObject.value)
+	idx := integer.value
+	length := int64(len(chars))
+
+	if idx < 0 {
+		idx += length
+	}
+	if idx < 0 || idx >= length {
+		return newPositionedError(token, "index %v out of range [0, %v).", integer.value, length)
+	}
+
+	return &StringValue{value: string(chars[idx])}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func evalHashIndexExpression(token Token, hash, index Object, env *Environment) Object {
+	hashObject, ok := hash.(*Hash)
+	if !ok {
+		return newPositionedError(token, "index operator not supported: %v.", hash.Type())
+	}
 	key, ok := index.(Hashable)
 	if !ok {
-		return newError("unusable as a hash key: %v. On line: %v, column: %v.", index.Type(), token.line, token.column)
+		return newPositionedError(token, "unusable as a hash key: %v.", index.Type())
 	}
 
 	pair, ok := hashObject.pairs[key.HashKey()]
+	if !ok && hashObject.factory != nil {
+		value := applyFunction(token, hashObject.factory, []Object{index}, env)
+		if isError(value) {
+			return value
+		}
+		// A frozen hash still hands out defaults, it just doesn't remember them.
+		if !hashObject.frozen {
+			hashObject.set(key.HashKey(), HashPair{key: index, value: value})
+		}
+		return value
+	}
 	if !ok {
 		return &NullObject
 	}
@@ -215,43 +342,152 @@ func evalHashIndexExpression(token Token, hash, index Object) Object {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// The body always runs once before the condition is checked.
+func evalDoWhileExpression(doWhile *DoWhileExpression, env *Environment) Object {
+	for {
+		// Each pass gets a fresh scope, and the condition is checked inside it so it can see what the body bound.
+		passEnv := newEnclosedEnvironment(env)
+		result := eval(doWhile.body, passEnv)
+		if result != nil && (result.Type() == RETURN_OBJ || isError(result)) {
+			return result
+		}
+
+		condition := eval(doWhile.condition, passEnv)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return &NullObject
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func evalForInExpression(forIn *ForInExpression, env *Environment) Object {
+	iterable := eval(forIn.iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	var items []Object
+	switch iterable := iterable.(type) {
+	case *Array:
+		items = iterable.elements
+	case *StringValue:
+		for _, char := range iterable.value {
+			items = append(items, &StringValue{value: string(char)})
+		}
+	case *Hash:
+		for _, pair := range iterable.orderedPairs() {
+			items = append(items, pair.key)
+		}
+	default:
+		return newPositionedError(
+			forIn.token,
+			"cannot iterate over %v.",
+			iterable.Type(),
+		)
+	}
+
+	for _, item := range items {
+		loopEnv := newEnclosedEnvironment(env)
+		loopEnv.Set(forIn.variable.value, item)
+
+		result := eval(forIn.body, loopEnv)
+		if result != nil && (result.Type() == RETURN_OBJ || isError(result)) {
+			return result
+		}
+	}
+
+	return &NullObject
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func evalIdentifier(token Token, node *Identifier, env *Environment) Object {
-	if val, ok := env.get(node.value); ok {
+	if val, ok := env.Get(node.value); ok {
 		return val
 	}
 
+	if builtin, ok := env.getBuiltin(node.value); ok {
+		return builtin
+	}
+
 	if builtin, ok := builtins[node.value]; ok {
 		return builtin
 	}
 
-	return newError(
-		"identifier not found {%v}. Found on line: %v, column: %v.",
+	return newPositionedError(
+		token,
+		"identifier not found {%v}.",
 		node.value,
-		token.line,
-		token.column,
 	)
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func evalIndexExpression(token Token, left, index Object) Object {
+func evalIndexExpression(token Token, left, index Object, env *Environment) Object {
 	switch {
-	case left.Type() == ARRAY_OBJ && index.Type() == INTEGER_OBJ:
-		return evalArrayIndexExpression(left, index)
+	case left.Type() == ARRAY_OBJ:
+		return evalArrayIndexExpression(token, left, index)
+	case left.Type() == STRING_OBJ:
+		return evalStringIndexExpression(token, left, index)
 	case left.Type() == HASH_OBJ:
-		return evalHashIndexExpression(token, left, index)
+		return evalHashIndexExpression(token, left, index, env)
+	case left.Type() == MODULE_OBJ && index.Type() == STRING_OBJ:
+		return evalMemberExpression(token, left, index.(*StringValue).value)
 	default:
-		return newError(
-			"index operator not supported: %v. On line %v, column: %v.",
+		return newPositionedError(
+			token,
+			"index operator not supported: %v.",
 			left.Type(),
-			token.line,
-			token.column,
 		)
 	}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func evalMemberExpression(token Token, object Object, member string) Object {
+	module, ok := object.(*Module)
+	if !ok {
+		return evalMethod(token, object, member)
+	}
+
+	value, ok := module.bindings[member]
+	if !ok {
+		return newPositionedError(
+			token,
+			"module %v has no member {%v}.",
+			module.name,
+			member,
+		)
+	}
+
+	return value
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func evalMethod(token Token, receiver Object, name string) Object {
+	methods, ok := methodTable[receiver.Type()]
+	if !ok {
+		return newPositionedError(token, "member access not supported: %v.", receiver.Type())
+	}
+	if !slices.Contains(methods, name) {
+		return newPositionedError(token, "%v has no method {%v}.", receiver.Type(), name)
+	}
+
+	builtin := builtins[name]
+	bound := func(env *Environment, args ...Object) Object {
+		return builtin.fn(env, append([]Object{receiver}, args...)...)
+	}
+
+	return &Builtin{fn: bound}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func evalIfExpression(ifExpr *IfExpression, env *Environment) Object {
 	condition := eval(ifExpr.condition, env)
 	if isError(condition) {
@@ -270,13 +506,11 @@ func evalIfExpression(ifExpr *IfExpression, env *Environment) Object {
 // --------------------------------------------------------------------------------------------------------------------
 
 func isTruthy(object Object) bool {
-	switch object {
-	case &NullObject:
-		return false
-	case &TrueObject:
-		return true
-	case &FalseObject:
+	switch object := object.(type) {
+	case *Null:
 		return false
+	case *Boolean:
+		return object.value
 	default:
 		return true
 	}
@@ -284,7 +518,7 @@ func isTruthy(object Object) bool {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func evalInfixExpr(token Token, left, right Object, operator string) Object {
+func evalInfixExpr(token Token, left, right Object, operator string, env *Environment) Object {
 	switch {
 	case left.Type() == INTEGER_OBJ && right.Type() == INTEGER_OBJ:
 		return evalIntegerInfixExpr(token, left, right, operator)
@@ -292,36 +526,111 @@ func evalInfixExpr(token Token, left, right Object, operator string) Object {
 		return evalFloatInfixExpr(token, left, right, operator)
 	case left.Type() == STRING_OBJ && right.Type() == STRING_OBJ:
 		return evalStringInfixExpression(token, operator, left, right)
+	case operator == "+" && left.Type() == ARRAY_OBJ && right.Type() == ARRAY_OBJ:
+		leftElems := left.(*Array).elements
+		rightElems := right.(*Array).elements
+		elements := make([]Object, 0, len(leftElems)+len(rightElems))
+		elements = append(elements, leftElems...)
+		return &Array{elements: append(elements, rightElems...)}
+	case operator == "*" && isRepeatable(left) && right.Type() == INTEGER_OBJ:
+		return evalRepeatInfixExpr(token, left, right.(*Integer).value)
+	case operator == "*" && left.Type() == INTEGER_OBJ && isRepeatable(right):
+		return evalRepeatInfixExpr(token, right, left.(*Integer).value)
+	case findOverload(left, right, operator) != nil:
+		return evalOverloadedInfixExpr(token, findOverload(left, right, operator), left, right, env)
+	case left.Type() == BOOL_OBJ && right.Type() == BOOL_OBJ && (operator == "==" || operator == "!="):
+		// Compare by value so a Boolean that bypassed nativeBoolToBoolObj still behaves.
+		equal := left.(*Boolean).value == right.(*Boolean).value
+		return nativeBoolToBoolObj(equal == (operator == "=="))
 	case operator == "==":
 		return nativeBoolToBoolObj(left == right)
 	case operator == "!=":
 		return nativeBoolToBoolObj(left != right)
 	case left.Type() != right.Type():
-		return newError(
-			"mismatched types found when evaluating infix expression {%v, %v}. On line: %v, column: %v",
+		return newPositionedError(
+			token,
+			"mismatched types found when evaluating infix expression {%v, %v}.",
 			left.Type(),
 			right.Type(),
-			token.line,
-			token.column,
 		)
 	default:
-		return newError(
-			"invalid operator found when evaluating infix expression {%v %v %v}. On line %v, column %v.",
+		return newPositionedError(
+			token,
+			"invalid operator found when evaluating infix expression {%v %v %v}.",
 			left.Type(),
 			operator,
 			right.Type(),
-			token.line,
-			token.column,
 		)
 	}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func isRepeatable(obj Object) bool {
+	return obj.Type() == STRING_OBJ || obj.Type() == ARRAY_OBJ
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func evalRepeatInfixExpr(token Token, value Object, count int64) Object {
+	if count < 0 {
+		return newPositionedError(token, "cannot repeat %v a negative number of times (%v).", value.Type(), count)
+	}
+
+	repeated, ok := repeatValue(value, count)
+	if !ok {
+		return newPositionedError(token, "repeated %v would be longer than %v.", value.Type(), MAX_REPEAT_LENGTH)
+	}
+
+	return repeated
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func findOverload(left, right Object, operator string) Object {
+	method, ok := overloadMethods[operator]
+	if !ok {
+		return nil
+	}
+
+	key := (&StringValue{value: method}).HashKey()
+	for _, operand := range []Object{left, right} {
+		hash, ok := operand.(*Hash)
+		if !ok {
+			continue
+		}
+		if pair, ok := hash.pairs[key]; ok {
+			switch pair.value.(type) {
+			case *Function, *Builtin:
+				return pair.value
+			}
+		}
+	}
+
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func evalOverloadedInfixExpr(token Token, fn, left, right Object, env *Environment) Object {
+	interp := env.interp
+	if interp.overloadDepth >= MAX_OVERLOAD_DEPTH {
+		return newPositionedError(token, "maximum operator overload depth exceeded.")
+	}
+
+	interp.overloadDepth += 1
+	defer func() { interp.overloadDepth -= 1 }()
+
+	return applyFunction(token, fn, []Object{left, right}, env)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func evalFloatInfixExpr(token Token, left, right Object, operator string) Object {
 	leftVal := left.(*Float).value
 	rightVal := right.(*Float).value
 
+	// Float division by zero is left to IEEE 754 and produces +Inf, -Inf or NaN.
 	switch operator {
 	case "+":
 		return &Float{value: leftVal + rightVal}
@@ -347,12 +656,16 @@ func evalIntegerInfixExpr(token Token, left, right Object, operator string) Obje
 		return &Integer{value: leftVal + rightVal}
 	case "-":
 		return &Integer{value: leftVal - rightVal}
-	case "/":
-		return &Integer{value: leftVal / rightVal}
+	case "/", "%":
+		if rightVal == 0 {
+			return newPositionedError(token, "division by zero.")
+		}
+		if operator == "/" {
+			return &Integer{value: leftVal / rightVal}
+		}
+		return &Integer{value: leftVal % rightVal}
 	case "*":
 		return &Integer{value: leftVal * rightVal}
-	case "%":
-		return &Integer{value: leftVal % rightVal}
 	default:
 		return evalOtherInfixOperators(token, leftVal, rightVal, operator)
 	}
@@ -375,13 +688,12 @@ func evalOtherInfixOperators[T int64 | float64](token Token, left, right T, oper
 	case "==":
 		return nativeBoolToBoolObj(left == right)
 	default:
-		return newError(
-			"invalid operator found when evaluating infix expression {%v %v %v}. On line %v, column %v.",
+		return newPositionedError(
+			token,
+			"invalid operator found when evaluating infix expression {%v %v %v}.",
 			left,
 			operator,
 			right,
-			token.line,
-			token.column,
 		)
 	}
 }
@@ -389,21 +701,78 @@ func evalOtherInfixOperators[T int64 | float64](token Token, left, right T, oper
 // --------------------------------------------------------------------------------------------------------------------
 
 func evalStringInfixExpression(token Token, operator string, left, right Object) Object {
-	if operator != "+" {
-		return newError(
-			"invalid operator found when evaluating infix expression {%v %v %v}. On line %v, column %v.",
+	leftVal := left.(*StringValue).value
+	rightVal := right.(*StringValue).value
+
+	switch operator {
+	case "+":
+		return &StringValue{value: leftVal + rightVal}
+	case "<":
+		return nativeBoolToBoolObj(leftVal < rightVal)
+	case "<=":
+		return nativeBoolToBoolObj(leftVal <= rightVal)
+	case ">":
+		return nativeBoolToBoolObj(leftVal > rightVal)
+	case ">=":
+		return nativeBoolToBoolObj(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBoolObj(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBoolObj(leftVal != rightVal)
+	default:
+		return newPositionedError(
+			token,
+			"invalid operator found when evaluating infix expression {%v %v %v}.",
 			left.Type(),
 			operator,
 			right.Type(),
-			token.line,
-			token.column,
 		)
 	}
+}
 
-	leftVal := left.(*StringValue).value
-	rightVal := right.(*StringValue).value
+// --------------------------------------------------------------------------------------------------------------------
 
-	return &StringValue{value: leftVal + rightVal}
+// Postfix operators update the variable and evaluate to its previous value.
+func evalPostfixExpression(node *PostfixExpression, env *Environment) Object {
+	current := evalIdentifier(node.left.token, node.left, env)
+	if isError(current) {
+		return current
+	}
+
+	if env.isConst(node.left.value) {
+		return constantReassignmentError(node.token, node.left.value)
+	}
+
+	delta := int64(1)
+	if node.operator == "--" {
+		delta = -1
+	}
+
+	var updated Object
+	switch current := current.(type) {
+	case *Integer:
+		updated = &Integer{value: current.value + delta}
+	case *Float:
+		updated = &Float{value: current.value + float64(delta)}
+	default:
+		return newPositionedError(
+			node.token,
+			"operator {%v} not supported for %v.",
+			node.operator,
+			current.Type(),
+		)
+	}
+
+	if _, ok := env.assign(node.left.value, updated); !ok {
+		return newPositionedError(
+			node.token,
+			"cannot apply {%v} to builtin {%v}.",
+			node.operator,
+			node.left.value,
+		)
+	}
+
+	return current
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -415,11 +784,10 @@ func evalPrefixExpression(token Token, operator string, right Object) Object {
 	case "-":
 		return evalMinusPrefixExpr(token, right)
 	default:
-		return newError(
-			"invalid operator in prefix position {%v}. On line: %v, column: %v",
+		return newPositionedError(
+			token,
+			"invalid operator in prefix position {%v}.",
 			operator,
-			token.line,
-			token.column,
 		)
 	}
 }
@@ -427,16 +795,7 @@ func evalPrefixExpression(token Token, operator string, right Object) Object {
 // --------------------------------------------------------------------------------------------------------------------
 
 func evalBangOperatorExpr(right Object) Object {
-	switch right {
-	case &TrueObject:
-		return &FalseObject
-	case &FalseObject:
-		return &TrueObject
-	case &NullObject:
-		return &TrueObject
-	default:
-		return &FalseObject
-	}
+	return nativeBoolToBoolObj(!isTruthy(right))
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -449,11 +808,10 @@ func evalMinusPrefixExpr(token Token, right Object) Object {
 		return &Float{value: -right.(*Float).value}
 	}
 
-	return newError(
-		"invalid operator {%v}. On line: %v, column: %v.",
+	return newPositionedError(
+		token,
+		"invalid operator {%v}.",
 		token.literal,
-		token.line,
-		token.column,
 	)
 }
 
@@ -461,34 +819,105 @@ func evalMinusPrefixExpr(token Token, right Object) Object {
 // Helpers
 // --------------------------------------------------------------------------------------------------------------------
 
-func applyFunction(token Token, fn Object, args []Object) Object {
+func applyFunction(token Token, fn Object, args []Object, env *Environment) Object {
 	switch fn := fn.(type) {
 	case *Function:
-		extendedEnv := extendFunctionEnv(fn, args)
+		if err := checkArity(token, fn, len(args)); err != nil {
+			return err
+		}
+		interp := env.interp
+		if interp.callDepth >= interp.maxCallDepth {
+			return newPositionedError(
+				token,
+				"maximum recursion depth exceeded (%v).",
+				interp.maxCallDepth,
+			)
+		}
+		interp.callDepth += 1
+		defer func() { interp.callDepth -= 1 }()
+
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
 		evaluated := eval(fn.body, extendedEnv)
 		return unwrapReturnValue(evaluated)
 	case *Builtin:
-		return fn.fn(args...)
+		result := fn.fn(env, args...)
+		// Builtins have no access to the call site, so their errors are positioned here. The error may be shared,
+		// so a positioned copy is returned.
+		if err, ok := result.(*Error); ok && err.line == 0 {
+			return &Error{message: err.message, line: token.line, column: token.column}
+		}
+		return result
 	default:
-		return newError(
-			"attempted to eval something that wasn't a function {%v}. On line: %v, column: %v.",
+		return newPositionedError(
+			token,
+			"attempted to eval something that wasn't a function {%v}.",
 			fn.Type(),
-			token.line,
-			token.column,
 		)
 	}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func extendFunctionEnv(fn *Function, args []Object) *Environment {
+func checkArity(token Token, fn *Function, argCount int) *Error {
+	required := 0
+	for _, value := range fn.defaults {
+		if value == nil {
+			required += 1
+		}
+	}
+
+	if argCount >= required && (fn.rest != nil || argCount <= len(fn.parameters)) {
+		return nil
+	}
+
+	var want string
+	switch {
+	case fn.rest != nil:
+		want = fmt.Sprintf("at least %v", required)
+	case required != len(fn.parameters):
+		want = fmt.Sprintf("%v to %v", required, len(fn.parameters))
+	default:
+		want = fmt.Sprintf("%v", required)
+	}
+
+	return newPositionedError(
+		token,
+		"wrong number of arguments passed to function. Got %v, want %v.",
+		argCount,
+		want,
+	)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func extendFunctionEnv(fn *Function, args []Object) (*Environment, Object) {
 	env := newEnclosedEnvironment(fn.env)
 
 	for idx, param := range fn.parameters {
-		env.set(param.value, args[idx])
+		if idx < len(args) {
+			env.Set(param.value, args[idx])
+			continue
+		}
+
+		value := eval(fn.defaults[idx], env)
+		if isError(value) {
+			return nil, value
+		}
+		env.Set(param.value, value)
+	}
+
+	if fn.rest != nil {
+		extra := make([]Object, 0)
+		if len(args) > len(fn.parameters) {
+			extra = append(extra, args[len(fn.parameters):]...)
+		}
+		env.Set(fn.rest.value, &Array{elements: extra})
 	}
 
-	return env
+	return env, nil
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -503,9 +932,10 @@ func unwrapReturnValue(object Object) Object {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// An exit request stops evaluation the same way an error does.
 func isError(object Object) bool {
 	if object != nil {
-		return object.Type() == ERR_OBJ
+		return object.Type() == ERR_OBJ || object.Type() == EXIT_OBJ
 	}
 
 	return false
@@ -513,6 +943,7 @@ func isError(object Object) bool {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// All booleans should come from here so identity comparisons against TrueObject and FalseObject hold.
 func nativeBoolToBoolObj(input bool) *Boolean {
 	if input {
 		return &TrueObject
@@ -527,3 +958,15 @@ func newError(format string, vars ...interface{}) *Error {
 }
 
 // --------------------------------------------------------------------------------------------------------------------
+
+func constantReassignmentError(token Token, name string) *Error {
+	return newPositionedError(token, "cannot reassign constant {%v}.", name)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func newPositionedError(token Token, format string, vars ...interface{}) *Error {
+	return &Error{message: fmt.Sprintf(format, vars...), line: token.line, column: token.column}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
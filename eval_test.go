@@ -0,0 +1,483 @@
+package monkey
+
+import (
+	"bytes"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------------------------------------------
+
+type evalCase struct {
+	input    string
+	expected string
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func testEval(t *testing.T, input string) Object {
+	t.Helper()
+
+	result, err := New().Run(input)
+	if parseErr, ok := err.(*ParseError); ok {
+		t.Fatalf("parsing %q failed: %v", input, parseErr)
+	}
+
+	return result
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func runEvalCases(t *testing.T, cases []evalCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		result := testEval(t, tc.input)
+		if result == nil {
+			t.Errorf("%q: got no result, want %v", tc.input, tc.expected)
+			continue
+		}
+		if got := result.Inspect(); got != tc.expected {
+			t.Errorf("%q: got %v, want %v", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Truthiness
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestIsTruthy(t *testing.T) {
+	cases := []struct {
+		object   Object
+		expected bool
+	}{
+		{&TrueObject, true},
+		{&FalseObject, false},
+		{&NullObject, false},
+		// Separately allocated objects are judged by value, not identity.
+		{&Boolean{value: true}, true},
+		{&Boolean{value: false}, false},
+		{&Null{}, false},
+		{&Integer{value: 0}, true},
+		{&StringValue{value: ""}, true},
+		{&Array{}, true},
+	}
+
+	for _, tc := range cases {
+		if got := isTruthy(tc.object); got != tc.expected {
+			t.Errorf("isTruthy(%v): got %v, want %v", tc.object.Inspect(), got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestBooleanResultsAreInterned(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected *Boolean
+	}{
+		{`1 < 2`, &TrueObject},
+		{`3 == 3`, &TrueObject},
+		{`1.5 > 1.0`, &TrueObject},
+		{`"a" == "a"`, &TrueObject},
+		{`!false`, &TrueObject},
+		{`!!1`, &TrueObject},
+		{`1 > 2`, &FalseObject},
+		{`!true`, &FalseObject},
+		{`"a" != "a"`, &FalseObject},
+	}
+
+	for _, tc := range cases {
+		if got := testEval(t, tc.input); got != Object(tc.expected) {
+			t.Errorf("%q: got %v (%p), want the interned %v (%p)", tc.input, got.Inspect(), got, tc.expected.Inspect(), tc.expected)
+		}
+	}
+
+	if first, second := testEval(t, `1 < 2`), testEval(t, `3 == 3`); first != second {
+		t.Errorf("two true comparisons returned different objects: %p and %p", first, second)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Function calls
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFunctionArity(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let f = fn(a, b) { a + b }; f(1, 2)`, "3"},
+		{`let f = fn(a, b) { a + b }; f(1)`, "Error [1:30]: wrong number of arguments passed to function. Got 1, want 2."},
+		{`let f = fn(a, b) { a + b }; f(1, 2, 3)`, "Error [1:30]: wrong number of arguments passed to function. Got 3, want 2."},
+		{`fn() { 1 }(2)`, "Error [1:11]: wrong number of arguments passed to function. Got 1, want 0."},
+		{`let f = fn(a, b = 10) { a + b }; [f(1), f(1, 2)]`, "[11, 3]"},
+		{`let f = fn(a, b = 10) { a + b }; f()`, "Error [1:35]: wrong number of arguments passed to function. Got 0, want 1 to 2."},
+		{`let f = fn(a, ...rest) { rest }; [f(1), f(1, 2, 3)]`, "[[], [2, 3]]"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestRecursionDepthLimit(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let f = fn(n) { f(n + 1) }; f(0)`, "Error [1:18]: maximum recursion depth exceeded (50000)."},
+		{`let f = fn(n) { map([n], f) }; f(0)`, "Error [1:20]: maximum recursion depth exceeded (50000)."},
+		{`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(1000)`, "1000"},
+	})
+
+	interp := New()
+	interp.SetMaxCallDepth(100)
+	result, err := interp.Run(`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; [f(50), f(200)]`)
+	if err == nil {
+		t.Fatalf("got %v, want the call depth limit to be hit", result.Inspect())
+	}
+	if got, want := result.Inspect(), "Error [1:47]: maximum recursion depth exceeded (100)."; got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// The depth counter unwinds after an error, so the interpreter stays usable.
+	result, _ = interp.Run(`f(50)`)
+	if got := result.Inspect(); got != "50" {
+		t.Errorf("after the limit was hit: got %v, want 50", got)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestDefaultParameters(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let f = fn(a, b = 10) { a + b }; [f(1), f(1, 2)]`, "[11, 3]"},
+		// Defaults are evaluated at call time and can use earlier parameters.
+		{`let f = fn(a, b = a * 2) { [a, b] }; [f(1), f(1, 5)]`, "[[1, 2], [1, 5]]"},
+		{`let n = 1; let f = fn(a = n) { a }; let n = 2; f()`, "2"},
+		{`let f = fn(a = 1 / 0) { a }; [f(5), f()]`, "Error [1:18]: division by zero."},
+		{`let f = fn(a, b = 10) { a + b }; f(1, 2, 3)`, "Error [1:35]: wrong number of arguments passed to function. Got 3, want 1 to 2."},
+	})
+
+	expectParseErrors(t, []string{`fn(a = 1, b) { a }`, `fn(a =) { a }`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestVariadicParameters(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let f = fn(first, ...rest) { [first, rest] }; [f(1), f(1, 2, 3)]`, "[[1, []], [1, [2, 3]]]"},
+		{`let f = fn(...rest) { rest }; [f(), f(1, 2)]`, "[[], [1, 2]]"},
+		{`let f = fn(a, b = 2, ...rest) { [a, b, rest] }; [f(1), f(1, 3, 4, 5)]`, "[[1, 2, []], [1, 3, [4, 5]]]"},
+		{`let f = fn(a, ...rest) { rest }; f()`, "Error [1:35]: wrong number of arguments passed to function. Got 0, want at least 1."},
+	})
+
+	expectParseErrors(t, []string{`fn(...a, b) { a }`, `fn(...) { 1 }`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Arithmetic
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestDivisionByZero(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`1 / 0`, "Error [1:3]: division by zero."},
+		{`5 % 0`, "Error [1:3]: division by zero."},
+		{`let x = 10; x / (x - 10)`, "Error [1:15]: division by zero."},
+		{`7 / 2`, "3"},
+		{`-7 % 3`, "-1"},
+		// Float division keeps IEEE semantics.
+		{`1.0 / 0.0`, "+Inf"},
+		{`-1.0 / 0.0`, "-Inf"},
+	})
+
+	if _, ok := testEval(t, `let f = fn() { 1 / 0 }; f()`).(*Error); !ok {
+		t.Errorf("division by zero inside a function did not produce an Error")
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestStringRepetition(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`"ab" * 3`, "ababab"},
+		{`"ab" * 0 == ""`, "true"},
+		{`"ab" * 1`, "ab"},
+		// The count can come first as well.
+		{`3 * "ab"`, "ababab"},
+		{`0 * "ab" == ""`, "true"},
+		{`"ab" * -1`, "Error [1:6]: cannot repeat STRING_OBJ a negative number of times (-1)."},
+		{`-1 * "ab"`, "Error [1:4]: cannot repeat STRING_OBJ a negative number of times (-1)."},
+		{`"ab" * 9223372036854775807`, "Error [1:6]: repeated STRING_OBJ would be longer than 16777216."},
+		{`"ab" * 1.5`, "Error [1:6]: mismatched types found when evaluating infix expression {STRING_OBJ, FLOAT}."},
+		{`"a" * "b"`, "Error [1:5]: invalid operator found when evaluating infix expression {STRING_OBJ * STRING_OBJ}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestArrayConcatenation(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let a = [1, 2]; let b = [3]; let c = a + b; [a, b, c]`, "[[1, 2], [3], [1, 2, 3]]"},
+		{`let a = []; let b = [1]; [a + b, b + a, a + a, a, b]`, "[[1], [1], [], [], [1]]"},
+		// Adding an empty array still gives a new array.
+		{`let a = [1]; let c = a + []; c == a`, "false"},
+		{`[1] + 1`, "Error [1:5]: mismatched types found when evaluating infix expression {ARRAY, INTEGER}."},
+		{`1 + [1]`, "Error [1:3]: mismatched types found when evaluating infix expression {INTEGER, ARRAY}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Statement separators
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestStatementSeparators(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{"let a = 1; let b = 2; a + b", "3"},
+		{"let a = 1\nlet b = 2\na + b", "3"},
+		{"let a = 1;\nlet b = 2;\na + b;\n", "3"},
+		{"let total = 1 +\n\t2 +\n\t3\ntotal", "6"},
+		{"let total = 1\n\t+ 2\n\t* 3\ntotal", "7"},
+		{"let f = fn(a,\n\tb) {\n\ta - b\n}\nf(5,\n\t3)", "2"},
+		{"let x = 5\nif (x > 3) {\n\t\"big\"\n}\nelse {\n\t\"small\"\n}", "big"},
+		{"let x = 1\n-1", "-1"},
+		{"let arr = [\n\t1,\n\t2,\n]\nlen(arr)", "2"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Operator overloading
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestOperatorOverloading(t *testing.T) {
+	complex := `
+let complex = fn(re, im) {
+	{
+		"re": re,
+		"im": im,
+		"__add__": fn(a, b) { complex(a["re"] + b["re"], a["im"] + b["im"]) },
+		"__eq__": fn(a, b) { if (a["re"] == b["re"]) { a["im"] == b["im"] } else { false } },
+	}
+}
+`
+	runEvalCases(t, []evalCase{
+		{complex + `let c = complex(1, 2) + complex(3, 4); [c["re"], c["im"]]`, "[4, 6]"},
+		{complex + `let c = complex(1, 2) + complex(3, 4) + complex(1, 1); [c["re"], c["im"]]`, "[5, 7]"},
+		{complex + `[complex(1, 2) == complex(1, 2), complex(1, 2) == complex(1, 3)]`, "[true, false]"},
+		// Either operand can carry the operator.
+		{`let h = {"__sub__": fn(a, b) { "sub" }}; [h - 1, 1 - h]`, "[sub, sub]"},
+		{`let h = {"__div__": fn(a, b) { 42 }}; h / 0`, "42"},
+		{`let h = {"__mul__": fn(a, b) { a * b }}; h * 2`, "Error [1:34]: maximum operator overload depth exceeded."},
+		{`let h = {"__add__": 1}; h + 1`, "Error [1:27]: mismatched types found when evaluating infix expression {HASH_OBJ, INTEGER}."},
+		{`{"a": 1} + {"b": 2}`, "Error [1:10]: invalid operator found when evaluating infix expression {HASH_OBJ + HASH_OBJ}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Error positions
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestErrorPositions(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{"let a = 1\nlet b = 2\n  1 + \"a\"", "Error [3:5]: mismatched types found when evaluating infix expression {INTEGER, STRING_OBJ}."},
+		{"let a = 1\n\nlet s = a + \"a\"", "Error [3:11]: mismatched types found when evaluating infix expression {INTEGER, STRING_OBJ}."},
+		// Builtin errors take the position of the call.
+		{"let a = 1\n  len(a)", "Error [2:6]: argument to 'len()' not supported, got INTEGER"},
+	})
+
+	// A builtin can hand back the same error every time, each call still reports its own position.
+	shared := newError("always fails")
+	interp := New()
+	interp.Env().RegisterBuiltin("fail", func(env *Environment, args ...Object) Object { return shared })
+
+	for _, tc := range []evalCase{{"fail()", "Error [1:5]: always fails"}, {"1\n  fail()", "Error [2:7]: always fails"}} {
+		if result, _ := interp.Run(tc.input); result.Inspect() != tc.expected {
+			t.Errorf("%q: got %v, want %v", tc.input, result.Inspect(), tc.expected)
+		}
+	}
+	if shared.line != 0 || shared.column != 0 {
+		t.Errorf("the shared error was positioned in place at [%v:%v]", shared.line, shared.column)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Loops
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestDoWhile(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let i = 0; do { i++ } while (i < 3); i`, "3"},
+		// The body runs once even when the condition starts out false.
+		{`let i = 10; let n = 0; do { n++ } while (i < 3); n`, "1"},
+		{`do { 1 } while (false)`, "null"},
+		// The condition sees bindings made in the body, which are gone once the loop ends.
+		{`let n = 0; do { n++; let done = n == 4 } while (!done); n`, "4"},
+		{`do { let done = true } while (!done); done`, "Error [1:39]: identifier not found {done}."},
+		{`let f = fn() { do { return 5 } while (true) }; f()`, "5"},
+		{`do { 1 } while (missing)`, "Error [1:17]: identifier not found {missing}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestForIn(t *testing.T) {
+	cases := []struct {
+		input  string
+		output string
+	}{
+		{`for (x in [1, 2, 3]) { print(x * 2, " ") }`, "2 4 6 "},
+		{`for (x in []) { print(x) }`, ""},
+		// Hashes give their keys in insertion order.
+		{`let h = {"z": 1, "a": 2, "m": 3}; for (k in h) { print(k, "=", h[k], " ") }`, "z=1 a=2 m=3 "},
+		// Strings give one string per character.
+		{`for (c in "héy") { print(c, len(c), " ") }`, "h1 é2 y1 "},
+		{`for (x in [[1, 2], [3]]) { for (y in x) { print(y) } }`, "123"},
+	}
+
+	for _, tc := range cases {
+		var buffer bytes.Buffer
+		interp := New()
+		interp.SetOutput(&buffer)
+		if _, err := interp.Run(tc.input); err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if got := buffer.String(); got != tc.output {
+			t.Errorf("%q: got output %q, want %q", tc.input, got, tc.output)
+		}
+	}
+
+	runEvalCases(t, []evalCase{
+		{`for (x in [1, 2]) { x }`, "null"},
+		{`let f = fn() { for (x in [1, 2, 3]) { if (x == 2) { return x } } }; f()`, "2"},
+		// The loop variable only lives inside the loop.
+		{`for (x in [1, 2]) { x }; x`, "Error [1:26]: identifier not found {x}."},
+		{`for (x in 5) { x }`, "Error [1:1]: cannot iterate over INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Indexing
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestNegativeIndices(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[1, 2, 3][-1]`, "3"},
+		{`[1, 2, 3][-3]`, "1"},
+		{`[1, 2, 3][-4]`, "Error [1:10]: index -4 out of range [0, 3)."},
+		{`[][-1]`, "Error [1:3]: index -1 out of range [0, 0)."},
+		{`"abc"[-1]`, "c"},
+		{`"abc"[0]`, "a"},
+		{`"abc"[-4]`, "Error [1:6]: index -4 out of range [0, 3)."},
+		{`""[-1]`, "Error [1:3]: index -1 out of range [0, 0)."},
+		// Strings count characters, not bytes.
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[-4]`, "é"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestWrongIndexTypes(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[1]["a"]`, "Error [1:4]: array index must be an INTEGER, got STRING_OBJ."},
+		{`[1][1.5]`, "Error [1:4]: array index must be an INTEGER, got FLOAT."},
+		{`[1][true]`, "Error [1:4]: array index must be an INTEGER, got BOOLEAN."},
+		{`[1][[0]]`, "Error [1:4]: array index must be an INTEGER, got ARRAY."},
+		{`[1][{}]`, "Error [1:4]: array index must be an INTEGER, got HASH_OBJ."},
+		{`"abc"["a"]`, "Error [1:6]: string index must be an INTEGER, got STRING_OBJ."},
+		{`"abc"[1.0]`, "Error [1:6]: string index must be an INTEGER, got FLOAT."},
+		{`{"a": 1}[[1]]`, "Error [1:9]: unusable as a hash key: ARRAY."},
+		{`{"a": 1}[{}]`, "Error [1:9]: unusable as a hash key: HASH_OBJ."},
+		{`{"a": 1}[fn(x) { x }]`, "Error [1:9]: unusable as a hash key: FUNCTION."},
+		{`5[0]`, "Error [1:2]: index operator not supported: INTEGER."},
+	})
+
+	// The guards hold even when called without evalIndexExpression picking the right one.
+	token := Token{line: 1, column: 1}
+	if result := evalArrayIndexExpression(token, &Array{}, &Float{value: 1}); result.Type() != ERR_OBJ {
+		t.Errorf("array indexed by a float: got %v", result.Inspect())
+	}
+	if result := evalArrayIndexExpression(token, &Integer{value: 1}, &Integer{value: 0}); result.Type() != ERR_OBJ {
+		t.Errorf("integer indexed as an array: got %v", result.Inspect())
+	}
+	if result := evalStringIndexExpression(token, &StringValue{value: "a"}, &StringValue{value: "a"}); result.Type() != ERR_OBJ {
+		t.Errorf("string indexed by a string: got %v", result.Inspect())
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Null handling
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestNullCoalescingChains(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{`a ?? b ?? c`, "((a ?? b) ?? c) "},
+		{`a ?? b + 1`, "(a ?? (b + 1)) "},
+	})
+
+	runEvalCases(t, []evalCase{
+		{`let n = first([]); n ?? 1 ?? 2`, "1"},
+		{`let n = first([]); n ?? n ?? 3`, "3"},
+		{`let n = first([]); n ?? n ?? n`, "null"},
+		// Only null falls through, other falsy values are kept.
+		{`let n = first([]); 0 ?? n ?? 3`, "0"},
+		{`let n = first([]); n ?? false ?? 3`, "false"},
+		{`let h = {"a": 1}; h["b"] ?? h["c"] ?? h["a"]`, "1"},
+		// The right side is only evaluated when it's needed.
+		{`1 ?? missing ?? missing`, "1"},
+		{`let n = first([]); n ?? missing`, "Error [1:25]: identifier not found {missing}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestOptionalChaining(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		// A null receiver gives null instead of an error.
+		{`let n = first([]); [n?.a, n?[0], n?.len()]`, "[null, null, null]"},
+		{`let n = first([]); n?.a?.b`, "null"},
+		{`let n = first([]); n?.a ?? "default"`, "default"},
+		// Anything else is accessed as usual.
+		{`let a = [1, 2]; [a?[0], a?.len()]`, "[1, 2]"},
+		{`"ab"?[1]`, "b"},
+		{`let h = {"a": {"b": 2}}; [h?["a"]?["b"], h?["x"]?["b"]]`, "[2, null]"},
+		{`5?[0]`, "Error [1:2]: index operator not supported: INTEGER."},
+		// Each step is guarded separately, so a plain access after a null still fails.
+		{`let n = first([]); n?.a.b`, "Error [1:24]: member access not supported: NULL."},
+		{`let n = first([]); n.a`, "Error [1:21]: member access not supported: NULL."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Comparisons
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestStringComparison(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`["a" < "b", "b" < "a", "ab" < "abc", "" < "a"]`, "[true, false, true, true]"},
+		// Comparison is by byte, so it is case sensitive and upper case sorts first.
+		{`["Abc" == "abc", "A" < "a", "a" > "B", "Z" < "a"]`, "[false, true, true, true]"},
+		{`["abc" == "abc", "abc" != "abc", "abc" <= "abc"]`, "[true, false, true]"},
+		{`["abc" >= "abc", "abc" < "abc", "abc" > "abc"]`, "[true, false, false]"},
+		{`let a = "ab"; let b = "a" + "b"; a == b`, "true"},
+		{`"a" < 1`, "Error [1:5]: mismatched types found when evaluating infix expression {STRING_OBJ, INTEGER}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Bindings
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestConstBindings(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`const a = [1]; a`, "[1]"},
+		{`const a = 1; const a = 2`, "Error [1:14]: cannot reassign constant {a}."},
+		{`const a = 1; let a = 2`, "Error [1:14]: cannot reassign constant {a}."},
+		{`const a = 1; a++`, "Error [1:15]: cannot reassign constant {a}."},
+		// If blocks share the enclosing scope, so they can't rebind it either.
+		{`const a = 1; if (true) { const a = 3; a }`, "Error [1:26]: cannot reassign constant {a}."},
+		// A function body is a new scope, where the name can be shadowed without touching the outer one.
+		{`const a = 1; let f = fn() { const a = 2; a }; [f(), a]`, "[2, 1]"},
+		{`const a = 1; let f = fn() { let a = 5; a++; a }; [f(), a]`, "[6, 1]"},
+		{`const a = 1; let f = fn() { a++ }; f()`, "Error [1:30]: cannot reassign constant {a}."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
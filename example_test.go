@@ -0,0 +1,58 @@
+package monkey_test
+
+import (
+	"fmt"
+	"monkey"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Host defined objects and builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+type tag struct {
+	labels []string
+}
+
+func (t *tag) Type() monkey.ObjectType { return "TAG" }
+
+func (t *tag) Inspect() string { return "#" + strings.Join(t.labels, "#") }
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func ExampleEnvironment_RegisterBuiltin() {
+	interp := monkey.New()
+	interp.Env().RegisterBuiltin("tag", func(env *monkey.Environment, args ...monkey.Object) monkey.Object {
+		labels := make([]string, 0, len(args))
+		for _, arg := range args {
+			labels = append(labels, arg.Inspect())
+		}
+		return &tag{labels: labels}
+	})
+
+	result, err := interp.Run(`let t = tag("go", 1 + 1); [t, len("abc")]`)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(result.Inspect())
+	// Output: [#go#2, 3]
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func ExampleInterpreter_Run() {
+	interp := monkey.New()
+
+	if _, err := interp.Run(`let = 1;`); err != nil {
+		fmt.Println(len(err.(*monkey.ParseError).Errors()) > 0)
+	}
+
+	result, _ := interp.Run(`let double = fn(x) { x * 2 }; double(21)`)
+	fmt.Println(result.Type(), result.Inspect())
+	// Output:
+	// true
+	// INTEGER 42
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,229 @@
+package monkey
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const INDENT = "    "
+
+// --------------------------------------------------------------------------------------------------------------------
+// Canonical formatting of parsed programs
+// --------------------------------------------------------------------------------------------------------------------
+
+// Parses src and returns it in canonical form. Parse failures give a *ParseError.
+func Format(src string) (string, error) {
+	parser := newParser(newLexer(src))
+	program := parser.parseProgram()
+	if len(parser.errors) != 0 {
+		return "", &ParseError{errors: parser.errors}
+	}
+
+	return format(program), nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func format(node Node) string {
+	return formatNode(node, 0)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatNode(node Node, depth int) string {
+	switch node := node.(type) {
+	// Statements
+	case *Program:
+		return formatStatements(node.statements, depth)
+	case *BlockStatement:
+		return formatBlock(node, depth)
+	case *ExpressionStatement:
+		switch node.expression.(type) {
+		case *IfExpression, *ForInExpression:
+			return formatNode(node.expression, depth)
+		}
+		return formatNode(node.expression, depth) + ";"
+	case *LetStatement:
+		return fmt.Sprintf("let %v = %v;", node.name.value, formatNode(node.value, depth))
+	case *ConstStatement:
+		return fmt.Sprintf("const %v = %v;", node.name.value, formatNode(node.value, depth))
+	case *ReturnStatement:
+		return fmt.Sprintf("return %v;", formatNode(node.value, depth))
+
+	// Expressions
+	case *ArrayLiteral:
+		return "[" + formatList(node.elements, depth) + "]"
+	case *CallExpression:
+		return fmt.Sprintf("%v(%v)", formatOperand(node.function, CALL, depth), formatList(node.arguments, depth))
+	case *DoWhileExpression:
+		return fmt.Sprintf("do %v while (%v)", formatBlock(node.body, depth), formatNode(node.condition, depth))
+	case *ForInExpression:
+		return fmt.Sprintf("for (%v in %v) %v", node.variable.value, formatNode(node.iterable, depth), formatBlock(node.body, depth))
+	case *FunctionLiteral:
+		return formatFunctionLiteral(node, depth)
+	case *HashLiteral:
+		return formatHashLiteral(node, depth)
+	case *IfExpression:
+		return formatIfExpression(node, depth)
+	case *IndexExpression:
+		return fmt.Sprintf("%v%v%v]", formatOperand(node.left, INDEX, depth), node.token.literal, formatNode(node.index, depth))
+	case *InfixExpression:
+		return formatInfixExpression(node, depth)
+	case *MemberExpression:
+		return fmt.Sprintf("%v%v%v", formatOperand(node.object, INDEX, depth), node.token.literal, node.member.value)
+	case *PostfixExpression:
+		return node.left.value + node.operator
+	case *PrefixExpression:
+		return node.operator + formatOperand(node.right, PREFIX+1, depth)
+	case *StringLiteral:
+		if node.token.tokenType == RAW_STRING {
+			return fmt.Sprintf("`%v`", node.value)
+		}
+		return fmt.Sprintf("\"%v\"", node.value)
+	case *CharLiteral:
+		return fmt.Sprintf("'%v'", node.token.literal)
+	case *BooleanLiteral, *FloatLiteral, *Identifier, *IntegerLiteral:
+		return node.tokenLiteral()
+	default:
+		return ""
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Format different nodes
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatStatements(stmts []Statement, depth int) string {
+	lines := make([]string, 0)
+
+	for _, stmt := range stmts {
+		lines = append(lines, strings.Repeat(INDENT, depth)+formatNode(stmt, depth))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatBlock(block *BlockStatement, depth int) string {
+	if len(block.statements) == 0 {
+		return "{}"
+	}
+
+	var buffer bytes.Buffer
+
+	buffer.WriteString("{\n")
+	buffer.WriteString(formatStatements(block.statements, depth+1))
+	buffer.WriteString("\n")
+	buffer.WriteString(strings.Repeat(INDENT, depth))
+	buffer.WriteString("}")
+
+	return buffer.String()
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatFunctionLiteral(node *FunctionLiteral, depth int) string {
+	params := make([]string, 0)
+
+	for idx, param := range node.parameters {
+		if node.defaults[idx] != nil {
+			params = append(params, fmt.Sprintf("%v = %v", param.value, formatNode(node.defaults[idx], depth)))
+		} else {
+			params = append(params, param.value)
+		}
+	}
+	if node.rest != nil {
+		params = append(params, "..."+node.rest.value)
+	}
+
+	return fmt.Sprintf("fn(%v) %v", strings.Join(params, ", "), formatBlock(node.body, depth))
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatHashLiteral(node *HashLiteral, depth int) string {
+	pairs := make([]string, 0)
+
+	for _, key := range node.keys {
+		pairs = append(pairs, fmt.Sprintf("%v: %v", formatNode(key, depth), formatNode(node.pairs[key], depth)))
+	}
+
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatIfExpression(node *IfExpression, depth int) string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(fmt.Sprintf("if (%v) ", formatNode(node.condition, depth)))
+	buffer.WriteString(formatBlock(node.consequence, depth))
+
+	switch {
+	case node.alternative == nil:
+	case node.alternative.token.tokenType == IF:
+		buffer.WriteString(" else ")
+		buffer.WriteString(formatNode(node.alternative.statements[0], depth))
+	default:
+		buffer.WriteString(" else ")
+		buffer.WriteString(formatBlock(node.alternative, depth))
+	}
+
+	return buffer.String()
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatInfixExpression(node *InfixExpression, depth int) string {
+	prec := tokenPrecedence(node.token.tokenType)
+	left := formatOperand(node.left, prec, depth)
+	// Operators are left associative, so an equal precedence on the right needs explicit grouping.
+	right := formatOperand(node.right, prec+1, depth)
+
+	return fmt.Sprintf("%v %v %v", left, node.operator, right)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatList(exprs []Expression, depth int) string {
+	items := make([]string, 0)
+
+	for _, expr := range exprs {
+		items = append(items, formatNode(expr, depth))
+	}
+
+	return strings.Join(items, ", ")
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func formatOperand(expr Expression, minPrec int, depth int) string {
+	formatted := formatNode(expr, depth)
+
+	if expressionPrecedence(expr) < minPrec {
+		return "(" + formatted + ")"
+	}
+
+	return formatted
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func expressionPrecedence(expr Expression) int {
+	switch expr := expr.(type) {
+	case *InfixExpression:
+		return tokenPrecedence(expr.token.tokenType)
+	case *PrefixExpression:
+		return PREFIX
+	case *DoWhileExpression, *ForInExpression, *FunctionLiteral, *IfExpression:
+		return LOWEST
+	default:
+		return INDEX + 1
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,74 @@
+package monkey
+
+import "testing"
+
+// --------------------------------------------------------------------------------------------------------------------
+// Canonical formatting
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFormatCanonicalForm(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let a=1;let b=2", "let a = 1;\nlet b = 2;"},
+		{"let x = (1 + 2) * 3 - -4", "let x = (1 + 2) * 3 - -4;"},
+		{"if (a) { 1 } else if (b) { 2 } else { 3 }", "if (a) {\n    1;\n} else if (b) {\n    2;\n} else {\n    3;\n}"},
+		// Trailing commas are dropped.
+		{"let f = fn(a, b = 2, ...rest,) { a + b }", "let f = fn(a, b = 2, ...rest) {\n    a + b;\n};"},
+		{"f(1, 2,)", "f(1, 2);"},
+		{"{\"a\": 1, \"b\": [1, 2,],}", "{\"a\": 1, \"b\": [1, 2]};"},
+		{"let f = fn() { if (x) { return 1 } \n 2 }", "let f = fn() {\n    if (x) {\n        return 1;\n    }\n    2;\n};"},
+		{"do { x++ } while (x < 3)", "do {\n    x++;\n} while (x < 3);"},
+		{"for (k in h) { puts(k) }", "for (k in h) {\n    puts(k);\n}"},
+		{"const c = `raw` ?? 'a'", "const c = `raw` ?? 'a';"},
+		{"a?.b?[0]", "a?.b?[0];"},
+		{"5 - -3", "5 - -3;"},
+	}
+
+	for _, tc := range cases {
+		got, err := Format(tc.input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.expected {
+			t.Errorf("%q: got %q, want %q", tc.input, got, tc.expected)
+		}
+
+		// Formatted code is already in canonical form.
+		again, err := Format(got)
+		if err != nil || again != got {
+			t.Errorf("%q: formatting again gave %q, %v", got, again, err)
+		}
+	}
+
+	if _, err := Format("let = 1"); err == nil {
+		t.Errorf("expected a parse error")
+	} else if _, ok := err.(*ParseError); !ok {
+		t.Errorf("got error %T, want *ParseError", err)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFormatKeepsBehaviour(t *testing.T) {
+	inputs := []string{
+		`let f = fn(x, scale = 2, ...rest,) { if (x < 0) { "neg" } else if (x == 0) { len(rest) } else { x * scale } }; [f(-1), f(0, 1, 2), f(3)]`,
+		`let a = 5; let b = 3; [a - -b, 5--3, (1 + 2) * 3]`,
+		"let i = 0; do { i++ } while (i < 3)\ni",
+	}
+
+	for _, input := range inputs {
+		formatted, err := Format(input)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+			continue
+		}
+		if got, want := testEval(t, formatted).Inspect(), testEval(t, input).Inspect(); got != want {
+			t.Errorf("%q: formatted code gave %v, want %v", input, got, want)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -1,13 +1,39 @@
-package main
+package monkey
 
-import "fmt"
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
 
 // --------------------------------------------------------------------------------------------------------------------
 // Language builtins
 // --------------------------------------------------------------------------------------------------------------------
 
+const MAX_POWER_SET_SIZE = 20
+
+// Largest number of selections combinations and permutations will build.
+const MAX_ARRANGEMENTS = 1 << 20
+
+// Largest limit primes_up_to will sieve, the sieve allocates one byte per number.
+const MAX_SIEVE_LIMIT = 1 << 26
+
+// Upper bound on the length of a repeated string or array, to stop runaway allocations.
+const MAX_REPEAT_LENGTH = 1 << 24
+
 var builtins = map[string]*Builtin{
-	"len": {fn: func(args ...Object) Object {
+	// len counts bytes for strings. Builtins that work on characters (rune_len, chars, ord, chunk_string) count runes.
+	"len": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 1 {
 			return newError("wrong number of args passed to len(). Got %v, want 1", len(args))
 		}
@@ -16,12 +42,17 @@ var builtins = map[string]*Builtin{
 			return &Integer{value: int64(len(arg.elements))}
 		case *StringValue:
 			return &Integer{value: int64(len(arg.value))}
+		case *Hash:
+			return &Integer{value: int64(len(arg.pairs))}
+		case *Function:
+			// Counts named parameters, a rest parameter is not included.
+			return &Integer{value: int64(len(arg.parameters))}
 		default:
 			return newError("argument to 'len()' not supported, got %v", args[0].Type())
 		}
 	},
 	},
-	"first": {fn: func(args ...Object) Object {
+	"first": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 1 {
 			return newError("first: wrong number of arguments. Got %v, want 1", len(args))
 		}
@@ -36,7 +67,7 @@ var builtins = map[string]*Builtin{
 		return &NullObject
 	},
 	},
-	"last": {fn: func(args ...Object) Object {
+	"last": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 1 {
 			return newError("last: wrong number of arguments. Got %v, want 1", len(args))
 		}
@@ -53,7 +84,7 @@ var builtins = map[string]*Builtin{
 		return &NullObject
 	},
 	},
-	"rest": {fn: func(args ...Object) Object {
+	"rest": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 1 {
 			return newError("rest: wrong number of arguments. Got %v, want 1", len(args))
 		}
@@ -72,7 +103,7 @@ var builtins = map[string]*Builtin{
 		return &NullObject
 	},
 	},
-	"push": {fn: func(args ...Object) Object {
+	"push": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 2 {
 			return newError("push: wrong number of arguments. Got %v, want 2", len(args))
 		}
@@ -90,7 +121,8 @@ var builtins = map[string]*Builtin{
 		return &Array{elements: newElements}
 	},
 	},
-	"range_array": {fn: func(args ...Object) Object {
+	// Kept for backward compatibility; prefer range, which is half-open and takes a step.
+	"range_array": {fn: func(env *Environment, args ...Object) Object {
 		if len(args) != 2 {
 			return newError("range_array: wrong number of arguments. Got %v, want 2", len(args))
 		}
@@ -108,13 +140,1847 @@ var builtins = map[string]*Builtin{
 		return &Array{elements: arr}
 	},
 	},
-	"puts": {fn: func(args ...Object) Object {
+	"range": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("range: wrong number of arguments. Got %v, want 2 or 3", len(args))
+		}
+		for _, arg := range args {
+			if arg.Type() != INTEGER_OBJ {
+				return newError("range: invalid type provided: %v. This function only accepts INTEGERS.", arg.Type())
+			}
+		}
+
+		start := args[0].(*Integer).value
+		stop := args[1].(*Integer).value
+		step := int64(1)
+		if len(args) == 3 {
+			step = args[2].(*Integer).value
+		}
+		if step == 0 {
+			return newError("range: step must not be zero.")
+		}
+
+		arr := make([]Object, 0)
+		for i := start; (step > 0 && i < stop) || (step < 0 && i > stop); i += step {
+			arr = append(arr, &Integer{value: i})
+			// Stop before i + step wraps around.
+			if (step > 0 && i > math.MaxInt64-step) || (step < 0 && i < math.MinInt64-step) {
+				break
+			}
+		}
+
+		return &Array{elements: arr}
+	},
+	},
+	"puts": {fn: func(env *Environment, args ...Object) Object {
+		for _, arg := range args {
+			fmt.Fprintln(env.interp.output, arg.Inspect())
+		}
+		return &NullObject
+	},
+	},
+	// Formats the arguments like puts, one per line, but returns the text instead of printing it.
+	"sprint": {fn: func(env *Environment, args ...Object) Object {
+		lines := make([]string, 0, len(args))
+		for _, arg := range args {
+			lines = append(lines, arg.Inspect())
+		}
+		return &StringValue{value: strings.Join(lines, "\n")}
+	},
+	},
+	// Writes the arguments back to back, with no separator and no trailing newline.
+	"print": {fn: func(env *Environment, args ...Object) Object {
 		for _, arg := range args {
-			fmt.Println(arg.inspect())
+			fmt.Fprint(env.interp.output, arg.Inspect())
+		}
+		return &NullObject
+	},
+	},
+	"exit": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) > 1 {
+			return newError("exit: wrong number of arguments. Got %v, want 0 or 1", len(args))
+		}
+
+		code := 0
+		if len(args) == 1 {
+			if args[0].Type() != INTEGER_OBJ {
+				return newError("exit: argument to exit must be an INTEGER, got %v.", args[0].Type())
+			}
+			code = int(args[0].(*Integer).value)
+		}
+
+		// Leaving the process is up to the host, so embedding programs survive a script calling exit.
+		return &Exit{code: code}
+	},
+	},
+	"now": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) > 1 {
+			return newError("now: wrong number of arguments. Got %v, want 0 or 1", len(args))
+		}
+		if len(args) == 0 {
+			return &Integer{value: time.Now().UnixMilli()}
+		}
+
+		unit, ok := args[0].(*StringValue)
+		if !ok || unit.value != "unix" {
+			return newError("now: argument to now must be the String \"unix\", got %v.", args[0].Inspect())
+		}
+
+		return &Integer{value: time.Now().Unix()}
+	},
+	},
+	"sleep": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("sleep: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("sleep: argument to sleep must be an INTEGER, got %v.", args[0].Type())
 		}
+
+		ms := args[0].(*Integer).value
+		if ms < 0 {
+			return newError("sleep: duration must not be negative, got %v.", ms)
+		}
+
+		duration := time.Duration(math.MaxInt64)
+		if ms < math.MaxInt64/int64(time.Millisecond) {
+			duration = time.Duration(ms) * time.Millisecond
+		}
+
+		time.Sleep(duration)
 		return &NullObject
 	},
 	},
+	"combinations": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("combinations: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != INTEGER_OBJ {
+			return newError("combinations: invalid types provided: (%v, %v). Want (ARRAY, INTEGER).", args[0].Type(), args[1].Type())
+		}
+
+		arr := args[0].(*Array)
+		k := args[1].(*Integer).value
+		if k < 0 || k > int64(len(arr.elements)) {
+			return newError("combinations: k must be between 0 and %v, got %v.", len(arr.elements), k)
+		}
+		if !arrangementsWithinLimit(len(arr.elements), int(k), false) {
+			return newError("combinations: result would have more than %v selections.", MAX_ARRANGEMENTS)
+		}
+
+		return &Array{elements: arrangements(arr.elements, int(k), false)}
+	},
+	},
+	"permutations": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("permutations: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != INTEGER_OBJ {
+			return newError("permutations: invalid types provided: (%v, %v). Want (ARRAY, INTEGER).", args[0].Type(), args[1].Type())
+		}
+
+		arr := args[0].(*Array)
+		k := args[1].(*Integer).value
+		if k < 0 || k > int64(len(arr.elements)) {
+			return newError("permutations: k must be between 0 and %v, got %v.", len(arr.elements), k)
+		}
+		if !arrangementsWithinLimit(len(arr.elements), int(k), true) {
+			return newError("permutations: result would have more than %v selections.", MAX_ARRANGEMENTS)
+		}
+
+		return &Array{elements: arrangements(arr.elements, int(k), true)}
+	},
+	},
+	"to_floats": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_floats: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("to_floats: argument to to_floats must be an Array, got %v.", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		newElements := make([]Object, len(arr.elements))
+		for idx, elem := range arr.elements {
+			switch elem := elem.(type) {
+			case *Integer:
+				newElements[idx] = &Float{value: float64(elem.value)}
+			case *Float:
+				newElements[idx] = elem
+			default:
+				return newError("to_floats: non-numeric element at index %v, got %v.", idx, elem.Type())
+			}
+		}
+
+		return &Array{elements: newElements}
+	},
+	},
+	"to_ints": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_ints: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("to_ints: argument to to_ints must be an Array, got %v.", args[0].Type())
+		}
+
+		arr := args[0].(*Array)
+		newElements := make([]Object, len(arr.elements))
+		for idx, elem := range arr.elements {
+			switch elem := elem.(type) {
+			case *Integer:
+				newElements[idx] = elem
+			case *Float:
+				newElements[idx] = &Integer{value: int64(elem.value)}
+			default:
+				return newError("to_ints: non-numeric element at index %v, got %v.", idx, elem.Type())
+			}
+		}
+
+		return &Array{elements: newElements}
+	},
+	},
+	"squeeze_spaces": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("squeeze_spaces: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("squeeze_spaces: argument to squeeze_spaces must be a String, got %v.", args[0].Type())
+		}
+
+		str := args[0].(*StringValue).value
+
+		return &StringValue{value: strings.Join(strings.Fields(str), " ")}
+	},
+	},
+	"format": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) < 1 {
+			return newError("format: wrong number of arguments. Got %v, want at least 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("format: first argument to format must be a String, got %v.", args[0].Type())
+		}
+
+		template := args[0].(*StringValue).value
+		values := args[1:]
+		var buffer bytes.Buffer
+		used := 0
+
+		for idx := 0; idx < len(template); idx++ {
+			if idx+1 < len(template) && (template[idx:idx+2] == "{}" || template[idx:idx+2] == "%v") {
+				if used < len(values) {
+					buffer.WriteString(values[used].Inspect())
+				}
+				used += 1
+				idx += 1
+				continue
+			}
+			buffer.WriteByte(template[idx])
+		}
+
+		if used != len(values) {
+			return newError("format: template has %v placeholders but %v arguments were given.", used, len(values))
+		}
+
+		return &StringValue{value: buffer.String()}
+	},
+	},
+	"edit_distance": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("edit_distance: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != STRING_OBJ || args[1].Type() != STRING_OBJ {
+			return newError("edit_distance: invalid types provided: (%v, %v). Want (STRING, STRING).", args[0].Type(), args[1].Type())
+		}
+
+		from := []rune(args[0].(*StringValue).value)
+		to := []rune(args[1].(*StringValue).value)
+
+		return &Integer{value: int64(levenshtein(from, to))}
+	},
+	},
+	"upper": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("upper: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("upper: argument to upper must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: strings.ToUpper(args[0].(*StringValue).value)}
+	},
+	},
+	"lower": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("lower: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("lower: argument to lower must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: strings.ToLower(args[0].(*StringValue).value)}
+	},
+	},
+	"trim": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 && len(args) != 2 {
+			return newError("trim: wrong number of arguments. Got %v, want 1 or 2", len(args))
+		}
+		for _, arg := range args {
+			if arg.Type() != STRING_OBJ {
+				return newError("trim: arguments to trim must be Strings, got %v.", arg.Type())
+			}
+		}
+
+		str := args[0].(*StringValue).value
+		if len(args) == 2 {
+			return &StringValue{value: strings.Trim(str, args[1].(*StringValue).value)}
+		}
+
+		return &StringValue{value: strings.TrimSpace(str)}
+	},
+	},
+	"capitalize": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("capitalize: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("capitalize: argument to capitalize must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: capitalizeWords(args[0].(*StringValue).value, false)}
+	},
+	},
+	"title": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("title: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("title: argument to title must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: capitalizeWords(args[0].(*StringValue).value, true)}
+	},
+	},
+	"replace": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 3 && len(args) != 4 {
+			return newError("replace: wrong number of arguments. Got %v, want 3 or 4", len(args))
+		}
+		for _, arg := range args[:3] {
+			if arg.Type() != STRING_OBJ {
+				return newError("replace: first three arguments to replace must be Strings, got %v.", arg.Type())
+			}
+		}
+
+		count := int64(-1)
+		if len(args) == 4 {
+			if args[3].Type() != INTEGER_OBJ {
+				return newError("replace: count argument to replace must be an Integer, got %v.", args[3].Type())
+			}
+			count = args[3].(*Integer).value
+		}
+
+		str := args[0].(*StringValue).value
+		old := args[1].(*StringValue).value
+		replacement := args[2].(*StringValue).value
+
+		return &StringValue{value: strings.Replace(str, old, replacement, int(count))}
+	},
+	},
+	"count_substr": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("count_substr: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != STRING_OBJ || args[1].Type() != STRING_OBJ {
+			return newError("count_substr: invalid types provided: (%v, %v). Want (STRING, STRING).", args[0].Type(), args[1].Type())
+		}
+
+		// Follows strings.Count: an empty needle matches between every rune, giving the rune length plus one.
+		str := args[0].(*StringValue).value
+		needle := args[1].(*StringValue).value
+
+		return &Integer{value: int64(strings.Count(str, needle))}
+	},
+	},
+	"rune_len": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("rune_len: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("rune_len: argument to rune_len must be a String, got %v.", args[0].Type())
+		}
+
+		return &Integer{value: int64(utf8.RuneCountInString(args[0].(*StringValue).value))}
+	},
+	},
+	"chars": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("chars: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("chars: argument to chars must be a String, got %v.", args[0].Type())
+		}
+
+		elements := make([]Object, 0)
+		for _, r := range args[0].(*StringValue).value {
+			elements = append(elements, &StringValue{value: string(r)})
+		}
+
+		return &Array{elements: elements}
+	},
+	},
+	"ord": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("ord: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("ord: argument to ord must be a String, got %v.", args[0].Type())
+		}
+
+		// Works on runes rather than bytes, so multibyte characters give their Unicode code point.
+		runes := []rune(args[0].(*StringValue).value)
+		if len(runes) != 1 {
+			return newError("ord: expected a single character, got a string of length %v.", len(runes))
+		}
+
+		return &Integer{value: int64(runes[0])}
+	},
+	},
+	"chr": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("chr: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("chr: argument to chr must be an Integer, got %v.", args[0].Type())
+		}
+
+		code := args[0].(*Integer).value
+		if code < 0 || code > unicode.MaxRune || !utf8.ValidRune(rune(code)) {
+			return newError("chr: %v is not a valid code point.", code)
+		}
+
+		return &StringValue{value: string(rune(code))}
+	},
+	},
+	"digits": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("digits: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("digits: argument to digits must be an Integer, got %v.", args[0].Type())
+		}
+
+		// The sign is dropped, digits(-123) gives the digits of 123.
+		literal := strconv.FormatInt(args[0].(*Integer).value, 10)
+		literal = strings.TrimPrefix(literal, "-")
+		elements := make([]Object, len(literal))
+		for idx, digit := range literal {
+			elements[idx] = &Integer{value: int64(digit - '0')}
+		}
+
+		return &Array{elements: elements}
+	},
+	},
+	"from_digits": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("from_digits: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("from_digits: argument to from_digits must be an Array, got %v.", args[0].Type())
+		}
+
+		result := int64(0)
+		for idx, elem := range args[0].(*Array).elements {
+			digit, ok := elem.(*Integer)
+			if !ok || digit.value < 0 || digit.value > 9 {
+				return newError("from_digits: element at index %v is not a single digit Integer: %v.", idx, elem.Inspect())
+			}
+			if result > (math.MaxInt64-digit.value)/10 {
+				return newError("from_digits: result overflows an Integer.")
+			}
+			result = result*10 + digit.value
+		}
+
+		return &Integer{value: result}
+	},
+	},
+	"is_prime": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("is_prime: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("is_prime: argument to is_prime must be an Integer, got %v.", args[0].Type())
+		}
+
+		return nativeBoolToBoolObj(isPrime(args[0].(*Integer).value))
+	},
+	},
+	"primes_up_to": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("primes_up_to: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("primes_up_to: argument to primes_up_to must be an Integer, got %v.", args[0].Type())
+		}
+
+		limit := args[0].(*Integer).value
+		primes := make([]Object, 0)
+		if limit < 2 {
+			return &Array{elements: primes}
+		}
+		if limit > MAX_SIEVE_LIMIT {
+			return newError("primes_up_to: limit %v is too large, the maximum is %v.", limit, MAX_SIEVE_LIMIT)
+		}
+
+		composite := make([]bool, limit+1)
+		for i := int64(2); i <= limit; i++ {
+			if composite[i] {
+				continue
+			}
+			primes = append(primes, &Integer{value: i})
+			for j := i * i; j <= limit; j += i {
+				composite[j] = true
+			}
+		}
+
+		return &Array{elements: primes}
+	},
+	},
+	"factorial": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("factorial: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ {
+			return newError("factorial: argument to factorial must be an Integer, got %v.", args[0].Type())
+		}
+
+		n := args[0].(*Integer).value
+		if n < 0 {
+			return newError("factorial: argument must be non-negative, got %v.", n)
+		}
+
+		result := big.NewInt(1)
+		for i := int64(2); i <= n; i++ {
+			result.Mul(result, big.NewInt(i))
+			if !result.IsInt64() {
+				return newError("factorial: result of factorial(%v) overflows an Integer.", n)
+			}
+		}
+
+		return &Integer{value: result.Int64()}
+	},
+	},
+	"choose": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("choose: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != INTEGER_OBJ || args[1].Type() != INTEGER_OBJ {
+			return newError("choose: invalid types provided: (%v, %v). Want (INTEGER, INTEGER).", args[0].Type(), args[1].Type())
+		}
+
+		n := args[0].(*Integer).value
+		k := args[1].(*Integer).value
+		if n < 0 || k < 0 {
+			return newError("choose: arguments must be non-negative, got (%v, %v).", n, k)
+		}
+		if k > n {
+			return &Integer{value: 0}
+		}
+
+		steps := min(k, n-k)
+		result := big.NewInt(1)
+		for i := int64(0); i < steps; i++ {
+			result.Mul(result, big.NewInt(n-i))
+			result.Quo(result, big.NewInt(i+1))
+			if !result.IsInt64() {
+				return newError("choose: result of choose(%v, %v) overflows an Integer.", n, k)
+			}
+		}
+
+		return &Integer{value: result.Int64()}
+	},
+	},
+	"invert": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("invert: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != HASH_OBJ {
+			return newError("invert: argument to invert must be a Hash, got %v.", args[0].Type())
+		}
+
+		// Duplicate values collapse to a single key, the last pair visited wins.
+		inverted := newHash()
+		for _, pair := range args[0].(*Hash).orderedPairs() {
+			key, ok := pair.value.(Hashable)
+			if !ok {
+				return newError("invert: value unusable as hash key: %v.", pair.value.Type())
+			}
+			inverted.set(key.HashKey(), HashPair{key: pair.value, value: pair.key})
+		}
+
+		return inverted
+	},
+	},
+	"union": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("union: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != ARRAY_OBJ {
+			return newError("union: invalid types provided: (%v, %v). Want (ARRAY, ARRAY).", args[0].Type(), args[1].Type())
+		}
+
+		combined := append(append([]Object{}, args[0].(*Array).elements...), args[1].(*Array).elements...)
+
+		return &Array{elements: filterUnique(combined, func(Object) bool { return true })}
+	},
+	},
+	"intersection": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("intersection: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != ARRAY_OBJ {
+			return newError("intersection: invalid types provided: (%v, %v). Want (ARRAY, ARRAY).", args[0].Type(), args[1].Type())
+		}
+
+		other := newObjectSet(args[1].(*Array).elements)
+
+		return &Array{elements: filterUnique(args[0].(*Array).elements, other.contains)}
+	},
+	},
+	"difference": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("difference: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != ARRAY_OBJ {
+			return newError("difference: invalid types provided: (%v, %v). Want (ARRAY, ARRAY).", args[0].Type(), args[1].Type())
+		}
+
+		other := newObjectSet(args[1].(*Array).elements)
+		keep := func(obj Object) bool { return !other.contains(obj) }
+
+		return &Array{elements: filterUnique(args[0].(*Array).elements, keep)}
+	},
+	},
+	"with_defaults": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("with_defaults: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != HASH_OBJ || args[1].Type() != HASH_OBJ {
+			return newError("with_defaults: invalid types provided: (%v, %v). Want (HASH_OBJ, HASH_OBJ).", args[0].Type(), args[1].Type())
+		}
+
+		// Keys keep the order of the defaults, with any extra keys from the first hash after them.
+		merged := newHash()
+		for _, key := range args[1].(*Hash).order {
+			merged.set(key, args[1].(*Hash).pairs[key])
+		}
+		for _, key := range args[0].(*Hash).order {
+			merged.set(key, args[0].(*Hash).pairs[key])
+		}
+
+		return merged
+	},
+	},
+	"chunk_string": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("chunk_string: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != STRING_OBJ || args[1].Type() != INTEGER_OBJ {
+			return newError("chunk_string: invalid types provided: (%v, %v). Want (STRING, INTEGER).", args[0].Type(), args[1].Type())
+		}
+
+		size := args[1].(*Integer).value
+		if size <= 0 {
+			return newError("chunk_string: chunk size must be positive, got %v.", size)
+		}
+
+		runes := []rune(args[0].(*StringValue).value)
+		chunks := make([]Object, 0)
+		for start := int64(0); start < int64(len(runes)); start += size {
+			end := min(start+size, int64(len(runes)))
+			chunks = append(chunks, &StringValue{value: string(runes[start:end])})
+		}
+
+		return &Array{elements: chunks}
+	},
+	},
+	"homogeneous": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("homogeneous: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("homogeneous: argument to homogeneous must be an Array, got %v.", args[0].Type())
+		}
+
+		elements := args[0].(*Array).elements
+		for _, elem := range elements {
+			if elem.Type() != elements[0].Type() {
+				return nativeBoolToBoolObj(false)
+			}
+		}
+
+		return nativeBoolToBoolObj(true)
+	},
+	},
+	"safe_div": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("safe_div: wrong number of arguments. Got %v, want 2", len(args))
+		}
+
+		left, leftOk := args[0].(*Integer)
+		right, rightOk := args[1].(*Integer)
+		if leftOk && rightOk {
+			if right.value == 0 {
+				return &NullObject
+			}
+			return &Integer{value: left.value / right.value}
+		}
+
+		dividend, _, err := numericArg("safe_div", args[0])
+		divisor, _, err2 := numericArg("safe_div", args[1])
+		if err != nil || err2 != nil {
+			return newError("safe_div: invalid types provided: (%v, %v). Want numbers.", args[0].Type(), args[1].Type())
+		}
+		if divisor == 0 {
+			return &NullObject
+		}
+
+		return &Float{value: dividend / divisor}
+	},
+	},
+	"to_camel": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_camel: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("to_camel: argument to to_camel must be a String, got %v.", args[0].Type())
+		}
+
+		words := splitIdentifierWords(args[0].(*StringValue).value)
+		for idx, word := range words {
+			if idx > 0 {
+				runes := []rune(word)
+				runes[0] = unicode.ToUpper(runes[0])
+				words[idx] = string(runes)
+			}
+		}
+
+		return &StringValue{value: strings.Join(words, "")}
+	},
+	},
+	"to_snake": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_snake: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("to_snake: argument to to_snake must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: strings.Join(splitIdentifierWords(args[0].(*StringValue).value), "_")}
+	},
+	},
+	"to_kebab": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_kebab: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("to_kebab: argument to to_kebab must be a String, got %v.", args[0].Type())
+		}
+
+		return &StringValue{value: strings.Join(splitIdentifierWords(args[0].(*StringValue).value), "-")}
+	},
+	},
+	"moving_average": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("moving_average: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ || args[1].Type() != INTEGER_OBJ {
+			return newError("moving_average: invalid types provided: (%v, %v). Want (ARRAY, INTEGER).", args[0].Type(), args[1].Type())
+		}
+
+		window := args[1].(*Integer).value
+		if window <= 0 {
+			return newError("moving_average: window must be positive, got %v.", window)
+		}
+
+		elements := args[0].(*Array).elements
+		values := make([]float64, len(elements))
+		for idx, elem := range elements {
+			value, _, err := numericArg("moving_average", elem)
+			if err != nil {
+				return newError("moving_average: non-numeric element at index %v, got %v.", idx, elem.Type())
+			}
+			values[idx] = value
+		}
+
+		averages := make([]Object, 0)
+		sum := 0.0
+		for idx, value := range values {
+			sum += value
+			if int64(idx) >= window {
+				sum -= values[int64(idx)-window]
+			}
+			if int64(idx) >= window-1 {
+				averages = append(averages, &Float{value: sum / float64(window)})
+			}
+		}
+
+		return &Array{elements: averages}
+	},
+	},
+	"to_json": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("to_json: wrong number of arguments. Got %v, want 1", len(args))
+		}
+
+		var buffer bytes.Buffer
+		if err := writeJSON(&buffer, args[0]); err != nil {
+			return err
+		}
+
+		return &StringValue{value: buffer.String()}
+	},
+	},
+	"clamp_array": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 3 {
+			return newError("clamp_array: wrong number of arguments. Got %v, want 3", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("clamp_array: first argument to clamp_array must be an Array, got %v.", args[0].Type())
+		}
+
+		lo, _, loErr := numericArg("clamp_array", args[1])
+		hi, _, hiErr := numericArg("clamp_array", args[2])
+		if loErr != nil || hiErr != nil {
+			return newError("clamp_array: bounds must be numbers, got (%v, %v).", args[1].Type(), args[2].Type())
+		}
+		if lo > hi {
+			return newError("clamp_array: lower bound %v is greater than upper bound %v.", args[1].Inspect(), args[2].Inspect())
+		}
+
+		elements := args[0].(*Array).elements
+		clamped := make([]Object, len(elements))
+		for idx, elem := range elements {
+			value, _, err := numericArg("clamp_array", elem)
+			if err != nil {
+				return newError("clamp_array: non-numeric element at index %v, got %v.", idx, elem.Type())
+			}
+
+			bound := elem
+			if value < lo {
+				bound = args[1]
+			} else if value > hi {
+				bound = args[2]
+			}
+
+			// Any Float among the element and bounds promotes the result to a Float.
+			if elem.Type() == FLOAT_OBJ || args[1].Type() == FLOAT_OBJ || args[2].Type() == FLOAT_OBJ {
+				promoted, _, _ := numericArg("clamp_array", bound)
+				clamped[idx] = &Float{value: promoted}
+			} else {
+				clamped[idx] = bound
+			}
+		}
+
+		return &Array{elements: clamped}
+	},
+	},
+	"from_json": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("from_json: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("from_json: argument to from_json must be a String, got %v.", args[0].Type())
+		}
+
+		decoder := json.NewDecoder(strings.NewReader(args[0].(*StringValue).value))
+		decoder.UseNumber()
+
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return newError("from_json: invalid JSON: %v.", err)
+		}
+		if decoder.More() {
+			return newError("from_json: invalid JSON: unexpected data after top-level value.")
+		}
+
+		return fromJSONValue(value)
+	},
+	},
+	"argmin": {fn: func(env *Environment, args ...Object) Object {
+		return argExtreme("argmin", args, func(candidate, best float64) bool { return candidate < best })
+	},
+	},
+	"argmax": {fn: func(env *Environment, args ...Object) Object {
+		return argExtreme("argmax", args, func(candidate, best float64) bool { return candidate > best })
+	},
+	},
+	"default_hash": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("default_hash: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if !isCallable(args[0]) {
+			return newError("default_hash: argument to default_hash must be a function, got %v.", args[0].Type())
+		}
+
+		hash := newHash()
+		hash.factory = args[0]
+
+		return hash
+	},
+	},
+	"power_set": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("power_set: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("power_set: argument to power_set must be an Array, got %v.", args[0].Type())
+		}
+
+		elements := args[0].(*Array).elements
+		if len(elements) > MAX_POWER_SET_SIZE {
+			return newError("power_set: array has %v elements, the limit is %v.", len(elements), MAX_POWER_SET_SIZE)
+		}
+
+		// Subsets are ordered by size, then lexicographically by index within each size.
+		subsets := make([]Object, 0, 1<<len(elements))
+		for k := 0; k <= len(elements); k++ {
+			subsets = append(subsets, arrangements(elements, k, false)...)
+		}
+
+		return &Array{elements: subsets}
+	},
+	},
+	"has_key": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("has_key: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != HASH_OBJ {
+			return newError("has_key: first argument to has_key must be a Hash, got %v.", args[0].Type())
+		}
+		key, ok := args[1].(Hashable)
+		if !ok {
+			return newError("has_key: unusable as hash key: %v.", args[1].Type())
+		}
+
+		_, ok = args[0].(*Hash).pairs[key.HashKey()]
+		return nativeBoolToBoolObj(ok)
+	},
+	},
+	"merge": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("merge: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[0].Type() != HASH_OBJ || args[1].Type() != HASH_OBJ {
+			return newError("merge: invalid types provided: (%v, %v). Want (HASH_OBJ, HASH_OBJ).", args[0].Type(), args[1].Type())
+		}
+
+		merged := newHash()
+		for _, key := range args[0].(*Hash).order {
+			merged.set(key, args[0].(*Hash).pairs[key])
+		}
+		for _, key := range args[1].(*Hash).order {
+			merged.set(key, args[1].(*Hash).pairs[key])
+		}
+
+		return merged
+	},
+	},
+	"concat": {fn: func(env *Environment, args ...Object) Object {
+		elements := make([]Object, 0)
+		for idx, arg := range args {
+			arr, ok := arg.(*Array)
+			if !ok {
+				return newError("concat: argument %v to concat must be an Array, got %v.", idx+1, arg.Type())
+			}
+			elements = append(elements, arr.elements...)
+		}
+
+		return &Array{elements: elements}
+	},
+	},
+	// Negative bounds count from the end. Bounds are then clamped to the array, and a reversed range gives [].
+	"slice": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 && len(args) != 3 {
+			return newError("slice: wrong number of arguments. Got %v, want 2 or 3", len(args))
+		}
+		if args[0].Type() != ARRAY_OBJ {
+			return newError("slice: first argument to slice must be an Array, got %v.", args[0].Type())
+		}
+		for _, arg := range args[1:] {
+			if arg.Type() != INTEGER_OBJ {
+				return newError("slice: bounds must be INTEGERS, got %v.", arg.Type())
+			}
+		}
+
+		elements := args[0].(*Array).elements
+		length := int64(len(elements))
+		start := clampSliceBound(args[1].(*Integer).value, length)
+		end := length
+		if len(args) == 3 {
+			end = clampSliceBound(args[2].(*Integer).value, length)
+		}
+		if start >= end {
+			return &Array{elements: []Object{}}
+		}
+
+		sliced := make([]Object, end-start)
+		copy(sliced, elements[start:end])
+
+		return &Array{elements: sliced}
+	},
+	},
+	"keys": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("keys: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != HASH_OBJ {
+			return newError("keys: argument to keys must be a Hash, got %v.", args[0].Type())
+		}
+
+		keys := make([]Object, 0)
+		for _, pair := range args[0].(*Hash).orderedPairs() {
+			keys = append(keys, pair.key)
+		}
+
+		return &Array{elements: keys}
+	},
+	},
+	"values": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("values: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != HASH_OBJ {
+			return newError("values: argument to values must be a Hash, got %v.", args[0].Type())
+		}
+
+		values := make([]Object, 0)
+		for _, pair := range args[0].(*Hash).orderedPairs() {
+			values = append(values, pair.value)
+		}
+
+		return &Array{elements: values}
+	},
+	},
+	"abs": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("abs: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		value, isInteger, err := numericArg("abs", args[0])
+		if err != nil {
+			return err
+		}
+
+		if isInteger {
+			integer := args[0].(*Integer).value
+			if integer == math.MinInt64 {
+				return newError("abs: result overflows an INTEGER.")
+			}
+			if integer < 0 {
+				integer = -integer
+			}
+			return &Integer{value: integer}
+		}
+
+		return &Float{value: math.Abs(value)}
+	},
+	},
+	"sign": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("sign: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		value, _, err := numericArg("sign", args[0])
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case value > 0:
+			return &Integer{value: 1}
+		case value < 0:
+			return &Integer{value: -1}
+		default:
+			return &Integer{value: 0}
+		}
+	},
+	},
+	"repeat": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 2 {
+			return newError("repeat: wrong number of arguments. Got %v, want 2", len(args))
+		}
+		if args[1].Type() != INTEGER_OBJ {
+			return newError("repeat: count must be an INTEGER, got %v.", args[1].Type())
+		}
+
+		count := args[1].(*Integer).value
+		if count < 0 {
+			return newError("repeat: count must not be negative, got %v.", count)
+		}
+		if args[0].Type() != STRING_OBJ && args[0].Type() != ARRAY_OBJ {
+			return newError("repeat: first argument to repeat must be a String or Array, got %v.", args[0].Type())
+		}
+
+		repeated, ok := repeatValue(args[0], count)
+		if !ok {
+			return newError("repeat: result would be longer than %v.", MAX_REPEAT_LENGTH)
+		}
+
+		return repeated
+	},
+	},
+	// Arrays and hashes are copied recursively. Everything else, functions included, is returned as is.
+	"clone": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("clone: wrong number of arguments. Got %v, want 1", len(args))
+		}
+
+		return deepCopy(args[0])
+	},
+	},
+	// Freezes the hash in place and returns it. Nested values are not frozen. Arrays have no in-place mutation to guard
+	// yet, so they aren't accepted.
+	"freeze": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("freeze: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != HASH_OBJ {
+			return newError("freeze: argument to freeze must be a Hash, got %v.", args[0].Type())
+		}
+
+		args[0].(*Hash).frozen = true
+
+		return args[0]
+	},
+	},
+	"tokenize": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("tokenize: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("tokenize: argument to tokenize must be a String, got %v.", args[0].Type())
+		}
+
+		// The trailing EOF token is left out, the array ends with the last real token.
+		lexer := newLexer(args[0].(*StringValue).value)
+		tokens := make([]Object, 0)
+		for tok := lexer.nextToken(); tok.tokenType != EOF; tok = lexer.nextToken() {
+			tokens = append(tokens, tokenToHash(tok))
+		}
+
+		return &Array{elements: tokens}
+	},
+	},
+	// Removes a binding from the scope unset is called in. Outer scopes are left alone, so shadowed names reappear.
+	"unset": {fn: func(env *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("unset: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("unset: argument to unset must be a String, got %v.", args[0].Type())
+		}
+
+		name := args[0].(*StringValue).value
+		if env.consts[name] {
+			return newError("unset: cannot unset constant {%v}.", name)
+		}
+
+		return nativeBoolToBoolObj(env.delete(name))
+	},
+	},
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Builtins that call back into the evaluator are registered at init to avoid an initialization cycle
+// --------------------------------------------------------------------------------------------------------------------
+
+func init() {
+	builtins["reduce_while"] = &Builtin{fn: reduceWhile}
+	builtins["import"] = &Builtin{fn: importModule}
+	builtins["each"] = &Builtin{fn: each}
+	builtins["map"] = &Builtin{fn: mapArray}
+	builtins["filter"] = &Builtin{fn: filterArray}
+	builtins["reduce"] = &Builtin{fn: reduceArray}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func reduceWhile(env *Environment, args ...Object) Object {
+	if len(args) != 4 {
+		return newError("reduce_while: wrong number of arguments. Got %v, want 4", len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("reduce_while: first argument to reduce_while must be an Array, got %v.", args[0].Type())
+	}
+	if !isCallable(args[2]) || !isCallable(args[3]) {
+		return newError("reduce_while: third and fourth arguments must be functions, got (%v, %v).", args[2].Type(), args[3].Type())
+	}
+
+	acc := args[1]
+	for _, elem := range args[0].(*Array).elements {
+		keepGoing := applyFunction(Token{}, args[3], []Object{acc}, env)
+		if isError(keepGoing) {
+			return keepGoing
+		}
+		if !isTruthy(keepGoing) {
+			break
+		}
+
+		acc = applyFunction(Token{}, args[2], []Object{acc, elem}, env)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+func each(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("each: wrong number of arguments. Got %v, want 2", len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("each: first argument to each must be an Array, got %v.", args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("each: second argument to each must be a function, got %v.", args[1].Type())
+	}
+
+	for _, elem := range args[0].(*Array).elements {
+		result := applyFunction(Token{}, args[1], []Object{elem}, env)
+		if isError(result) {
+			return result
+		}
+	}
+
+	return &NullObject
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func mapArray(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("map: wrong number of arguments. Got %v, want 2", len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("map: first argument to map must be an Array, got %v.", args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("map: second argument to map must be a function, got %v.", args[1].Type())
+	}
+
+	elements := args[0].(*Array).elements
+	result := make([]Object, 0, len(elements))
+	for _, elem := range elements {
+		mapped := applyFunction(Token{}, args[1], []Object{elem}, env)
+		if isError(mapped) {
+			return mapped
+		}
+		result = append(result, mapped)
+	}
+
+	return &Array{elements: result}
+}
+
+func filterArray(env *Environment, args ...Object) Object {
+	if len(args) != 2 {
+		return newError("filter: wrong number of arguments. Got %v, want 2", len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("filter: first argument to filter must be an Array, got %v.", args[0].Type())
+	}
+	if !isCallable(args[1]) {
+		return newError("filter: second argument to filter must be a function, got %v.", args[1].Type())
+	}
+
+	result := make([]Object, 0)
+	for _, elem := range args[0].(*Array).elements {
+		keep := applyFunction(Token{}, args[1], []Object{elem}, env)
+		if isError(keep) {
+			return keep
+		}
+		if isTruthy(keep) {
+			result = append(result, elem)
+		}
+	}
+
+	return &Array{elements: result}
+}
+
+func reduceArray(env *Environment, args ...Object) Object {
+	if len(args) != 3 {
+		return newError("reduce: wrong number of arguments. Got %v, want 3", len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("reduce: first argument to reduce must be an Array, got %v.", args[0].Type())
+	}
+	if !isCallable(args[2]) {
+		return newError("reduce: third argument to reduce must be a function, got %v.", args[2].Type())
+	}
+
+	acc := args[1]
+	for _, elem := range args[0].(*Array).elements {
+		acc = applyFunction(Token{}, args[2], []Object{acc, elem}, env)
+		if isError(acc) {
+			return acc
+		}
+	}
+
+	return acc
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func importModule(env *Environment, args ...Object) Object {
+	if len(args) != 1 {
+		return newError("import: wrong number of arguments. Got %v, want 1", len(args))
+	}
+	if args[0].Type() != STRING_OBJ {
+		return newError("import: argument to import must be a String, got %v.", args[0].Type())
+	}
+	if !env.interp.fileIOEnabled {
+		return newError("import: file access is disabled. Start the interpreter with --allow-io to enable it.")
+	}
+
+	path := args[0].(*StringValue).value
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return newError("import: %v", err)
+	}
+	if module, ok := env.interp.modules[absPath]; ok {
+		return module
+	}
+	if env.interp.loading[absPath] {
+		return newError("import: import cycle through %v", path)
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return newError("import: %v", err)
+	}
+
+	parser := newParser(newLexer(string(source)))
+	program := parser.parseProgram()
+	if len(parser.errors) != 0 {
+		return newError("import: could not parse %v: %v", path, strings.Join(parser.errors, " "))
+	}
+
+	// Modules see the builtins the host registered, with eval and vars bound to the module itself.
+	moduleEnv := newEnvironment(env.interp)
+	for name, builtin := range env.interp.env.builtins {
+		moduleEnv.builtins[name] = builtin
+	}
+	loadNativeBuiltins(moduleEnv)
+
+	env.interp.loading[absPath] = true
+	result := eval(program, moduleEnv)
+	delete(env.interp.loading, absPath)
+	if isError(result) {
+		return result
+	}
+
+	bindings := make(map[string]Object)
+	for _, stmt := range program.statements {
+		switch stmt := stmt.(type) {
+		case *LetStatement:
+			bindings[stmt.name.value], _ = moduleEnv.Get(stmt.name.value)
+		case *ConstStatement:
+			bindings[stmt.name.value], _ = moduleEnv.Get(stmt.name.value)
+		}
+	}
+
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	module := &Module{name: name, bindings: bindings}
+	env.interp.modules[absPath] = module
+
+	return module
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------------------------------------------
+
+// Selections are produced in lexicographic order of the source indices.
+func arrangements(elements []Object, k int, ordered bool) []Object {
+	result := make([]Object, 0)
+	used := make([]bool, len(elements))
+	picked := make([]Object, 0, k)
+
+	var build func(start int)
+	build = func(start int) {
+		if len(picked) == k {
+			selection := make([]Object, k)
+			copy(selection, picked)
+			result = append(result, &Array{elements: selection})
+			return
+		}
+
+		for idx := start; idx < len(elements); idx++ {
+			if used[idx] {
+				continue
+			}
+
+			used[idx] = true
+			picked = append(picked, elements[idx])
+			if ordered {
+				build(0)
+			} else {
+				build(idx + 1)
+			}
+			picked = picked[:len(picked)-1]
+			used[idx] = false
+		}
+	}
+	build(0)
+
+	return result
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Counts the selections arrangements would build, stopping as soon as the count passes MAX_ARRANGEMENTS.
+func arrangementsWithinLimit(n, k int, ordered bool) bool {
+	count := int64(1)
+	for i := range k {
+		count *= int64(n - i)
+		if !ordered {
+			count /= int64(i + 1)
+		}
+		if count > MAX_ARRANGEMENTS {
+			return false
+		}
+	}
+
+	return true
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func levenshtein(from, to []rune) int {
+	previous := make([]int, len(to)+1)
+	current := make([]int, len(to)+1)
+	for col := range previous {
+		previous[col] = col
+	}
+
+	for row := 1; row <= len(from); row++ {
+		current[0] = row
+		for col := 1; col <= len(to); col++ {
+			cost := 1
+			if from[row-1] == to[col-1] {
+				cost = 0
+			}
+			current[col] = min(previous[col]+1, current[col-1]+1, previous[col-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(to)]
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func capitalizeWords(str string, everyWord bool) string {
+	runes := []rune(str)
+	atWordStart := true
+	capitalized := false
+
+	for idx, r := range runes {
+		if unicode.IsSpace(r) {
+			atWordStart = true
+			continue
+		}
+		if atWordStart && (everyWord || !capitalized) {
+			runes[idx] = unicode.ToUpper(r)
+			capitalized = true
+		}
+		atWordStart = false
+	}
+
+	return string(runes)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func isPrime(n int64) bool {
+	if n < 2 {
+		return false
+	}
+	if n%2 == 0 || n%3 == 0 {
+		return n == 2 || n == 3
+	}
+
+	for i := int64(5); i*i <= n; i += 6 {
+		if n%i == 0 || n%(i+2) == 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func objectsEqual(left, right Object) bool {
+	if left.Type() != right.Type() {
+		return false
+	}
+
+	switch left := left.(type) {
+	case *Float:
+		return left.value == right.(*Float).value
+	case Hashable:
+		return left.HashKey() == right.(Hashable).HashKey()
+	case *Null:
+		return true
+	case *Array:
+		other := right.(*Array)
+		if len(left.elements) != len(other.elements) {
+			return false
+		}
+		for idx := range left.elements {
+			if !objectsEqual(left.elements[idx], other.elements[idx]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return left == right
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Hashable objects are looked up by HashKey, everything else falls back to a linear objectsEqual scan.
+type objectSet struct {
+	keys   map[HashKey]bool
+	others []Object
+}
+
+func newObjectSet(elements []Object) *objectSet {
+	set := &objectSet{keys: make(map[HashKey]bool)}
+	for _, elem := range elements {
+		set.add(elem)
+	}
+
+	return set
+}
+
+func (s *objectSet) add(obj Object) {
+	if hashable, ok := obj.(Hashable); ok {
+		s.keys[hashable.HashKey()] = true
+		return
+	}
+
+	s.others = append(s.others, obj)
+}
+
+func (s *objectSet) contains(obj Object) bool {
+	if hashable, ok := obj.(Hashable); ok {
+		return s.keys[hashable.HashKey()]
+	}
+
+	for _, other := range s.others {
+		if objectsEqual(obj, other) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func filterUnique(elements []Object, keep func(Object) bool) []Object {
+	seen := newObjectSet(nil)
+	result := make([]Object, 0)
+
+	for _, elem := range elements {
+		if seen.contains(elem) || !keep(elem) {
+			continue
+		}
+		seen.add(elem)
+		result = append(result, elem)
+	}
+
+	return result
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Splits on '_', '-', whitespace and lower-to-upper case changes, returning lower cased words.
+func splitIdentifierWords(str string) []string {
+	words := make([]string, 0)
+	current := make([]rune, 0)
+	var previous rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, strings.ToLower(string(current)))
+			current = current[:0]
+		}
+	}
+
+	for _, r := range str {
+		switch {
+		case r == '_' || r == '-' || unicode.IsSpace(r):
+			flush()
+		case unicode.IsUpper(r) && (unicode.IsLower(previous) || unicode.IsDigit(previous)):
+			flush()
+			current = append(current, r)
+		case unicode.IsLower(r) && unicode.IsUpper(previous) && len(current) > 1:
+			// The last capital of an acronym starts the next word, so HTTPServer splits as HTTP and Server.
+			current = current[:len(current)-1]
+			flush()
+			current = append(current, previous, r)
+		default:
+			current = append(current, r)
+		}
+		previous = r
+	}
+	flush()
+
+	return words
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Hash entries are written in insertion order, so the output follows the order keys were added.
+func writeJSON(buffer *bytes.Buffer, obj Object) *Error {
+	switch obj := obj.(type) {
+	case *Null:
+		buffer.WriteString("null")
+	case *Boolean:
+		buffer.WriteString(strconv.FormatBool(obj.value))
+	case *Integer:
+		buffer.WriteString(strconv.FormatInt(obj.value, 10))
+	case *Float:
+		if math.IsNaN(obj.value) || math.IsInf(obj.value, 0) {
+			return newError("to_json: %v is not representable in JSON.", obj.Inspect())
+		}
+		buffer.WriteString(strconv.FormatFloat(obj.value, 'g', -1, 64))
+	case *StringValue:
+		encoded, _ := json.Marshal(obj.value)
+		buffer.Write(encoded)
+	case *Array:
+		buffer.WriteString("[")
+		for idx, elem := range obj.elements {
+			if idx > 0 {
+				buffer.WriteString(",")
+			}
+			if err := writeJSON(buffer, elem); err != nil {
+				return err
+			}
+		}
+		buffer.WriteString("]")
+	case *Hash:
+		pairs := obj.orderedPairs()
+		for _, pair := range pairs {
+			if pair.key.Type() != STRING_OBJ {
+				return newError("to_json: hash keys must be Strings, got %v.", pair.key.Type())
+			}
+		}
+
+		buffer.WriteString("{")
+		for idx, pair := range pairs {
+			if idx > 0 {
+				buffer.WriteString(",")
+			}
+			writeJSON(buffer, pair.key)
+			buffer.WriteString(":")
+			if err := writeJSON(buffer, pair.value); err != nil {
+				return err
+			}
+		}
+		buffer.WriteString("}")
+	default:
+		return newError("to_json: %v is not serializable.", obj.Type())
+	}
+
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func fromJSONValue(value interface{}) Object {
+	switch value := value.(type) {
+	case nil:
+		return &NullObject
+	case bool:
+		return nativeBoolToBoolObj(value)
+	case json.Number:
+		if !strings.ContainsAny(value.String(), ".eE") {
+			if integer, err := value.Int64(); err == nil {
+				return &Integer{value: integer}
+			}
+		}
+		float, err := value.Float64()
+		if err != nil {
+			return newError("from_json: invalid number %v.", value)
+		}
+		return &Float{value: float}
+	case string:
+		return &StringValue{value: value}
+	case []interface{}:
+		elements := make([]Object, len(value))
+		for idx, elem := range value {
+			elements[idx] = fromJSONValue(elem)
+			if isError(elements[idx]) {
+				return elements[idx]
+			}
+		}
+		return &Array{elements: elements}
+	case map[string]interface{}:
+		// The decoded map has no order, so objects come back with their keys sorted.
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		hash := newHash()
+		for _, key := range keys {
+			hashKey := &StringValue{value: key}
+			converted := fromJSONValue(value[key])
+			if isError(converted) {
+				return converted
+			}
+			hash.set(hashKey.HashKey(), HashPair{key: hashKey, value: converted})
+		}
+		return hash
+	default:
+		return newError("from_json: unsupported JSON value %v.", value)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Ties keep the first index, an empty array gives -1.
+func argExtreme(name string, args []Object, better func(candidate, best float64) bool) Object {
+	if len(args) != 1 {
+		return newError("%v: wrong number of arguments. Got %v, want 1", name, len(args))
+	}
+	if args[0].Type() != ARRAY_OBJ {
+		return newError("%v: argument to %v must be an Array, got %v.", name, name, args[0].Type())
+	}
+
+	bestIdx := -1
+	best := 0.0
+	for idx, elem := range args[0].(*Array).elements {
+		value, _, err := numericArg(name, elem)
+		if err != nil {
+			return newError("%v: non-numeric element at index %v, got %v.", name, idx, elem.Type())
+		}
+		if bestIdx == -1 || better(value, best) {
+			bestIdx = idx
+			best = value
+		}
+	}
+
+	return &Integer{value: int64(bestIdx)}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func isCallable(obj Object) bool {
+	switch obj.(type) {
+	case *Function, *Builtin:
+		return true
+	default:
+		return false
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func clampSliceBound(bound, length int64) int64 {
+	if bound < 0 {
+		bound += length
+	}
+
+	return max(0, min(bound, length))
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Reads an INTEGER or FLOAT argument as a float64, also reporting whether it was an INTEGER.
+func numericArg(name string, obj Object) (float64, bool, *Error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.value), true, nil
+	case *Float:
+		return obj.value, false, nil
+	default:
+		return 0, false, newError("%v: expected an INTEGER or FLOAT, got %v.", name, obj.Type())
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Repeats a String or Array count times. Reports false if the result would exceed MAX_REPEAT_LENGTH.
+func repeatValue(obj Object, count int64) (Object, bool) {
+	switch obj := obj.(type) {
+	case *StringValue:
+		if count > 0 && int64(len(obj.value)) > MAX_REPEAT_LENGTH/count {
+			return nil, false
+		}
+		return &StringValue{value: strings.Repeat(obj.value, int(count))}, true
+	case *Array:
+		if count > 0 && int64(len(obj.elements)) > MAX_REPEAT_LENGTH/count {
+			return nil, false
+		}
+		if len(obj.elements) == 0 {
+			return &Array{elements: []Object{}}, true
+		}
+		elements := make([]Object, 0, len(obj.elements)*int(count))
+		for range count {
+			elements = append(elements, obj.elements...)
+		}
+		return &Array{elements: elements}, true
+	default:
+		return nil, false
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func deepCopy(obj Object) Object {
+	switch obj := obj.(type) {
+	case *Array:
+		elements := make([]Object, 0, len(obj.elements))
+		for _, elem := range obj.elements {
+			elements = append(elements, deepCopy(elem))
+		}
+		return &Array{elements: elements}
+	case *Hash:
+		copied := newHash()
+		copied.factory = obj.factory
+		for _, key := range obj.order {
+			pair := obj.pairs[key]
+			copied.set(key, HashPair{key: pair.key, value: deepCopy(pair.value)})
+		}
+		return copied
+	default:
+		return obj
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func tokenToHash(tok Token) *Hash {
+	hash := newHash()
+	fields := []struct {
+		name  string
+		value Object
+	}{
+		{"type", &StringValue{value: string(tok.tokenType)}},
+		{"literal", &StringValue{value: tok.literal}},
+		{"line", &Integer{value: int64(tok.line)}},
+		{"column", &Integer{value: int64(tok.column)}},
+	}
+	for _, field := range fields {
+		key := &StringValue{value: field.name}
+		hash.set(key.HashKey(), HashPair{key: key, value: field.value})
+	}
+
+	return hash
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,873 @@
+package monkey
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Environment builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestUnset(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let x = 1; unset("x")`, "true"},
+		{`unset("missing")`, "false"},
+		{`let x = 1; unset("x"); let y = 2; vars()`, "[y]"},
+		// Deleting in an inner scope leaves the outer binding alone.
+		{`let x = 1; let f = fn() { let x = 2; unset("x") }; f(); x`, "1"},
+		{`let x = 1; let f = fn() { let x = 2; unset("x"); x }; f()`, "1"},
+		{`let x = 1; let f = fn() { unset("x") }; [f(), x]`, "[false, 1]"},
+		{`const c = 1; unset("c")`, "Error [1:19]: unset: cannot unset constant {c}."},
+		{`unset(1)`, "Error [1:6]: unset: argument to unset must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestImport(t *testing.T) {
+	dir := t.TempDir()
+	module := filepath.Join(dir, "helpers.monkey")
+	source := `
+let helper = fn(x) { x * 2 }
+const scale = 10
+let private = fn() { helper(scale) }
+`
+	if err := os.WriteFile(module, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	broken := filepath.Join(dir, "broken.monkey")
+	if err := os.WriteFile(broken, []byte("let = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []evalCase{
+		{`let mod = import("` + module + `"); mod["helper"](21)`, "42"},
+		{`let mod = import("` + module + `"); mod.helper(mod.scale)`, "20"},
+		{`let mod = import("` + module + `"); mod.private()`, "20"},
+		// The module is evaluated in its own environment.
+		{`import("` + module + `")` + "\nhelper", "Error [2:1]: identifier not found {helper}."},
+	}
+	for _, tc := range cases {
+		interp := New()
+		interp.EnableFileIO(true)
+		result, _ := interp.Run(tc.input)
+		if got := result.Inspect(); got != tc.expected {
+			t.Errorf("%q: got %v, want %v", tc.input, got, tc.expected)
+		}
+	}
+
+	interp := New()
+	interp.EnableFileIO(true)
+	result, _ := interp.Run(`import("` + broken + `")`)
+	if err, ok := result.(*Error); !ok || !strings.HasPrefix(err.message, "import: could not parse") {
+		t.Errorf("importing a broken module: got %v", result.Inspect())
+	}
+	result, _ = interp.Run(`import("` + filepath.Join(dir, "missing.monkey") + `")`)
+	if _, ok := result.(*Error); !ok {
+		t.Errorf("importing a missing module: got %v", result.Inspect())
+	}
+
+	// File access is off unless the host enables it.
+	result, _ = New().Run(`import("` + module + `")`)
+	if err, ok := result.(*Error); !ok || !strings.HasPrefix(err.message, "import: file access is disabled") {
+		t.Errorf("import with file access disabled: got %v", result.Inspect())
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestImportCyclesAndCaching(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, source string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	self := write("self.monkey", `let me = import("`+filepath.Join(dir, "self.monkey")+`")`)
+	write("a.monkey", `let b = import("`+filepath.Join(dir, "b.monkey")+`")`)
+	write("b.monkey", `let a = import("`+filepath.Join(dir, "a.monkey")+`")`)
+	counted := write("counted.monkey", `puts("loaded"); let value = host_double(21)`)
+
+	for _, input := range []string{`import("` + self + `")`, `import("` + filepath.Join(dir, "a.monkey") + `")`} {
+		interp := New()
+		interp.EnableFileIO(true)
+		result, _ := interp.Run(input)
+		if err, ok := result.(*Error); !ok || !strings.HasPrefix(err.message, "import: import cycle through") {
+			t.Errorf("%q: got %v, want an import cycle error", input, result.Inspect())
+		}
+	}
+
+	// A module is evaluated once per interpreter and sees the builtins the host registered.
+	var buffer bytes.Buffer
+	interp := New()
+	interp.EnableFileIO(true)
+	interp.SetOutput(&buffer)
+	interp.Env().RegisterBuiltin("host_double", func(env *Environment, args ...Object) Object {
+		return &Integer{value: args[0].(*Integer).value * 2}
+	})
+	result, err := interp.Run(`let a = import("` + counted + `"); let b = import("` + counted + `"); [a.value, b.value]`)
+	if err != nil || result.Inspect() != "[42, 42]" {
+		t.Errorf("repeated import: got %v, %v", result.Inspect(), err)
+	}
+	if got := buffer.String(); got != "loaded\n" {
+		t.Errorf("repeated import: got output %q, want the module evaluated once", got)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Array builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestSetOperations(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`union([1, 2, 3], [2, 3, 4])`, "[1, 2, 3, 4]"},
+		{`intersection([1, 2, 3], [2, 3, 4])`, "[2, 3]"},
+		{`difference([1, 2, 3], [2, 3, 4])`, "[1]"},
+		// Duplicates collapse and first occurrences keep their place.
+		{`[union([1, 1, 2], [2, 1]), intersection([1, 1, 2], [1]), difference([1, 1, 2], [])]`, "[[1, 2], [1], [1, 2]]"},
+		{`[union([], []), intersection([1], []), difference([], [1])]`, "[[], [], []]"},
+		// Unhashable elements are compared by value too.
+		{`union([[1]], [[1], [2]])`, "[[1], [2]]"},
+		{`union(1, [])`, "Error [1:6]: union: invalid types provided: (INTEGER, ARRAY). Want (ARRAY, ARRAY)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestHomogeneous(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[homogeneous([1, 2]), homogeneous(["a", "b"]), homogeneous([[1], ["a"]])]`, "[true, true, true]"},
+		{`[homogeneous([1, 2.0]), homogeneous([1, "1"]), homogeneous([true, first([])])]`, "[false, false, false]"},
+		{`[homogeneous([]), homogeneous(["a"])]`, "[true, true]"},
+		{`homogeneous(1)`, "Error [1:12]: homogeneous: argument to homogeneous must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestReduceWhile(t *testing.T) {
+	add := `let add = fn(acc, x) { acc + x };`
+	runEvalCases(t, []evalCase{
+		// Sum until the total reaches 6.
+		{add + `reduce_while([1, 2, 3, 4, 5], 0, add, fn(acc) { acc < 6 })`, "6"},
+		{add + `reduce_while([1, 2, 3], 0, add, fn(acc) { true })`, "6"},
+		{add + `[reduce_while([], 7, add, fn(acc) { true }), reduce_while([1, 2], 10, add, fn(acc) { acc < 5 })]`, "[7, 10]"},
+		{`reduce_while([1], 0, fn(acc, x) { 1 / 0 }, fn(acc) { true })`, "Error [1:37]: division by zero."},
+		{`reduce_while([1], 0, 1, fn(acc) { true })`, "Error [1:13]: reduce_while: third and fourth arguments must be functions, got (INTEGER, FUNCTION)."},
+		{`reduce_while(1, 0, fn(acc, x) { acc }, fn(acc) { true })`, "Error [1:13]: reduce_while: first argument to reduce_while must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestEach(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`each([1, 2, 3], fn(x) { x * 2 })`, "null"},
+		{`each([], fn(x) { 1 / 0 })`, "null"},
+		{`each([1, 2], fn(x) { 1 / 0 })`, "Error [1:24]: division by zero."},
+		{`each([1], fn(a, b) { a })`, "Error [1:5]: wrong number of arguments passed to function. Got 1, want 2."},
+		{`each(1, len)`, "Error [1:5]: each: first argument to each must be an Array, got INTEGER."},
+		{`each([1], 1)`, "Error [1:5]: each: second argument to each must be a function, got INTEGER."},
+	})
+
+	// The function runs once per element, in order.
+	var out bytes.Buffer
+	interp := New()
+	interp.SetOutput(&out)
+	if _, err := interp.Run(`each(["a", "b", "c"], puts)`); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := out.String(), "a\nb\nc\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestConcat(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`concat([1], [2])`, "[1, 2]"},
+		{`concat([1], [2, 3], [4])`, "[1, 2, 3, 4]"},
+		{`concat([], [], [])`, "[]"},
+		{`concat()`, "[]"},
+		{`let a = [1]; let c = concat(a, [2]); [a, c]`, "[[1], [1, 2]]"},
+		{`concat([1], 2)`, "Error [1:7]: concat: argument 2 to concat must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestSlice(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`slice([1, 2, 3], 1)`, "[2, 3]"},
+		{`slice([1, 2, 3, 4, 5], 1, 3)`, "[2, 3]"},
+		// Negative bounds count back from the end.
+		{`slice([1, 2, 3, 4, 5], -3, -1)`, "[3, 4]"},
+		{`slice([1, 2, 3, 4, 5], -2)`, "[4, 5]"},
+		// Out of range bounds are clamped, and reversed bounds give an empty array.
+		{`slice([1, 2, 3, 4, 5], -10, 2)`, "[1, 2]"},
+		{`slice([1, 2, 3, 4, 5], 2, 10)`, "[3, 4, 5]"},
+		{`slice([1, 2, 3, 4, 5], 3, 1)`, "[]"},
+		{`slice([1, 2, 3], -1, -2)`, "[]"},
+		{`slice([], 0)`, "[]"},
+		{`slice([1, 2, 3], "a")`, "Error [1:6]: slice: bounds must be INTEGERS, got STRING_OBJ."},
+		{`slice([1], 0, 1, 2)`, "Error [1:6]: slice: wrong number of arguments. Got 4, want 2 or 3"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestLen(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[len([]), len([1, 2])]`, "[0, 2]"},
+		// Strings are measured in bytes.
+		{`[len(""), len("abc"), len("héllo")]`, "[0, 3, 6]"},
+		{`[len({}), len({"a": 1, "b": 2})]`, "[0, 2]"},
+		{`[len(fn() { 1 }), len(fn(a, b) { a }), len(fn(a, b = 1, ...c) { a })]`, "[0, 2, 2]"},
+		{`len(1)`, "Error [1:4]: argument to 'len()' not supported, got INTEGER"},
+		{`len(1.5)`, "Error [1:4]: argument to 'len()' not supported, got FLOAT"},
+		{`len(true)`, "Error [1:4]: argument to 'len()' not supported, got BOOLEAN"},
+		{`len(first([]))`, "Error [1:4]: argument to 'len()' not supported, got NULL"},
+		{`len("a", "b")`, "Error [1:4]: wrong number of args passed to len(). Got 2, want 1"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Hash builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFreeze(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let h = freeze({"a": 1}); h["a"]`, "1"},
+		{`let h = {"a": 1}; freeze(h) == h`, "true"},
+		// Reading a missing key from a frozen default hash doesn't store the default.
+		{`let h = default_hash(fn(k) { len(k) }); h["abc"]; keys(h)`, "[abc]"},
+		{`let h = freeze(default_hash(fn(k) { len(k) })); [h["abc"], keys(h)]`, "[3, []]"},
+		{`freeze([1, 2])`, "Error [1:7]: freeze: argument to freeze must be a Hash, got ARRAY."},
+		{`[1, 2].freeze()`, "Error [1:7]: ARRAY has no method {freeze}."},
+		{`let h = {"a": 1}.freeze(); h["a"]`, "1"},
+		{`freeze()`, "Error [1:7]: freeze: wrong number of arguments. Got 0, want 1"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestInvert(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`invert({"a": 1, "b": 2})`, "{1: a, 2: b}"},
+		{`invert({})`, "{}"},
+		// The last key wins when values repeat.
+		{`invert({"a": 1, "b": 1})`, "{1: b}"},
+		{`invert(invert({"a": 1, "b": 2}))`, "{a: 1, b: 2}"},
+		{`invert({"a": [1]})`, "Error [1:7]: invert: value unusable as hash key: ARRAY."},
+		{`invert([1])`, "Error [1:7]: invert: argument to invert must be a Hash, got ARRAY."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestWithDefaults(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`with_defaults({"a": 1}, {"b": 2})`, "{b: 2, a: 1}"},
+		// Existing keys are never overwritten.
+		{`with_defaults({"a": 1, "b": 5}, {"b": 2, "c": 3})`, "{b: 5, c: 3, a: 1}"},
+		{`with_defaults({}, {})`, "{}"},
+		{`let h = {"a": 1}; with_defaults(h, {"b": 2}); keys(h)`, "[a]"},
+		{`with_defaults({"a": 1}, 1)`, "Error [1:14]: with_defaults: invalid types provided: (HASH_OBJ, INTEGER). Want (HASH_OBJ, HASH_OBJ)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestDefaultHash(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		// Missing keys get the factory's result, and it's stored.
+		{`let counts = default_hash(fn(k) { 0 }); [counts["a"], keys(counts)]`, "[0, [a]]"},
+		{`let h = default_hash(fn(k) { len(k) }); h["abc"]; h["de"]; [h["abc"], keys(h), values(h)]`, "[3, [abc, de], [3, 2]]"},
+		// has_key checks without calling the factory.
+		{`let h = default_hash(fn(k) { 0 }); h["a"]; [has_key(h, "a"), has_key(h, "b")]`, "[true, false]"},
+		{`let h = default_hash(fn(k) { 1 / 0 }); h["a"]`, "Error [1:32]: division by zero."},
+		{`default_hash(fn() { 0 })["a"]`, "Error [1:25]: wrong number of arguments passed to function. Got 1, want 0."},
+		{`let h = default_hash(fn(k) { 0 }); h[[1]]`, "Error [1:37]: unusable as a hash key: ARRAY."},
+		{`default_hash(0)`, "Error [1:13]: default_hash: argument to default_hash must be a function, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestHasKey(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`has_key({"a": 1}, "a")`, "true"},
+		{`has_key({"a": 1}, "b")`, "false"},
+		{`has_key({}, "a")`, "false"},
+		// A key stored with null is still present.
+		{`let h = {"a": first([])}; [has_key(h, "a"), h["a"]]`, "[true, null]"},
+		{`{1: 2}.has_key(1)`, "true"},
+		{`has_key({"a": 1}, [1])`, "Error [1:8]: has_key: unusable as hash key: ARRAY."},
+		{`has_key([1], 0)`, "Error [1:8]: has_key: first argument to has_key must be a Hash, got ARRAY."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestMerge(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		// Keys in the second hash win, and neither input changes.
+		{
+			`let a = {"x": 1, "y": 2}; let b = {"y": 3, "z": 4}; let m = merge(a, b); [m, a, b]`,
+			"[{x: 1, y: 3, z: 4}, {x: 1, y: 2}, {y: 3, z: 4}]",
+		},
+		{`merge({}, {})`, "{}"},
+		{`merge({"a": 1}, {})`, "{a: 1}"},
+		{`merge({"a": 1})`, "Error [1:6]: merge: wrong number of arguments. Got 1, want 2"},
+		{`merge({"a": 1}, [1])`, "Error [1:6]: merge: invalid types provided: (HASH_OBJ, ARRAY). Want (HASH_OBJ, HASH_OBJ)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestClone(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		// Looking up a missing key in a default hash stores it, which only touches the clone.
+		{`let h = default_hash(fn(k) { 0 }); let c = clone(h); c["a"]; [keys(h), keys(c)]`, "[[], [a]]"},
+		{
+			`let o = {"inner": default_hash(fn(k) { k })}; let c = clone(o); c["inner"]["x"]; [keys(o["inner"]), keys(c["inner"])]`,
+			"[[], [x]]",
+		},
+		// Nested arrays and hashes are copied rather than shared.
+		{`let a = [[1], {"k": 2}]; let c = clone(a); [c, c == a, c[0] == a[0], c[1] == a[1]]`, "[[[1], {k: 2}], false, false, false]"},
+		{`clone(5)`, "5"},
+		{`clone()`, "Error [1:6]: clone: wrong number of arguments. Got 0, want 1"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestHashInsertionOrder(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`let h = {"z": 1, "a": 2, "m": 3}; [keys(h), values(h)]`, "[[z, a, m], [1, 2, 3]]"},
+		{`{3: "c", 1: "a", 2: "b"}`, "{3: c, 1: a, 2: b}"},
+		// A repeated key keeps its first position and its last value.
+		{`let h = {"b": 1, "a": 2, "b": 3}; [keys(h), values(h)]`, "[[b, a], [3, 2]]"},
+		{`keys(merge({"b": 1, "a": 2}, {"c": 3, "b": 4}))`, "[b, a, c]"},
+	})
+
+	var buffer bytes.Buffer
+	interp := New()
+	interp.SetOutput(&buffer)
+	if _, err := interp.Run(`let h = {"z": 1, "a": 2, "m": 3}; for (k in h) { print(k, h[k], " ") }`); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	if got, want := buffer.String(), "z1 a2 m3 "; got != want {
+		t.Errorf("for-in over a hash: got %q, want %q", got, want)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Math builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCombinationsAndPermutations(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`combinations([1, 2, 3], 2)`, "[[1, 2], [1, 3], [2, 3]]"},
+		{`permutations([1, 2], 2)`, "[[1, 2], [2, 1]]"},
+		{`permutations([1, 2, 3], 2)[2]`, "[2, 1]"},
+		{`[combinations([1, 2], 0), permutations([], 0)]`, "[[[]], [[]]]"},
+		// Result sizes match C(5, k) and P(5, k).
+		{`map(range(0, 5), fn(k) { len(combinations(range(0, 5), k)) })`, "[1, 5, 10, 10, 5]"},
+		{`map(range(0, 5), fn(k) { len(permutations(range(0, 5), k)) })`, "[1, 5, 20, 60, 120]"},
+		{`[len(combinations(range(0, 5), 5)), len(permutations(range(0, 5), 5))]`, "[1, 120]"},
+		{`combinations([1], 2)`, "Error [1:13]: combinations: k must be between 0 and 1, got 2."},
+		{`permutations([1], -1)`, "Error [1:13]: permutations: k must be between 0 and 1, got -1."},
+		{`permutations(range(0, 12), 12)`, "Error [1:13]: permutations: result would have more than 1048576 selections."},
+		{`combinations(range(0, 60), 30)`, "Error [1:13]: combinations: result would have more than 1048576 selections."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestToFloatsAndToInts(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`to_ints([1, 2.9, -2.9])`, "[1, 2, -2]"},
+		{`to_floats([1, 2.5])`, "[1, 2.5]"},
+		{`to_ints(to_floats([1, 2.5, 3]))`, "[1, 2, 3]"},
+		{`to_floats([1, "a"])`, "Error [1:10]: to_floats: non-numeric element at index 1, got STRING_OBJ."},
+		{`to_ints(["a"])`, "Error [1:8]: to_ints: non-numeric element at index 0, got STRING_OBJ."},
+		{`to_floats(1)`, "Error [1:10]: to_floats: argument to to_floats must be an Array, got INTEGER."},
+	})
+
+	// Whole floats inspect like integers, so check the element types directly.
+	cases := []struct {
+		input    string
+		expected ObjectType
+	}{
+		{`to_floats([1, 2.5, -3])`, FLOAT_OBJ},
+		{`to_ints([1.5, 2, -0.5])`, INTEGER_OBJ},
+	}
+	for _, tc := range cases {
+		for _, elem := range testEval(t, tc.input).(*Array).elements {
+			if elem.Type() != tc.expected {
+				t.Errorf("%q: got %v of type %v, want %v", tc.input, elem.Inspect(), elem.Type(), tc.expected)
+			}
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestSafeDiv(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[safe_div(6, 3), safe_div(7, 2), safe_div(-7, 2), safe_div(0, 5)]`, "[2, 3, -3, 0]"},
+		// A float on either side gives float division.
+		{`[safe_div(7.0, 2), safe_div(1, 4.0)]`, "[3.5, 0.25]"},
+		{`[safe_div(1, 0), safe_div(1.0, 0.0), safe_div(1, 0.0)]`, "[null, null, null]"},
+		{`safe_div(1, "a")`, "Error [1:9]: safe_div: invalid types provided: (INTEGER, STRING_OBJ). Want numbers."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestMovingAverage(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`moving_average([1, 2, 3], 1)`, "[1, 2, 3]"},
+		{`moving_average([1, 2, 3, 4, 5], 2)`, "[1.5, 2.5, 3.5, 4.5]"},
+		{`moving_average([1.5, 2.5, 4], 3)`, "[2.6666666666666665]"},
+		{`moving_average([1, 2], 3)`, "[]"},
+		{`moving_average([1, 2], 0)`, "Error [1:15]: moving_average: window must be positive, got 0."},
+		{`moving_average([1, "a"], 1)`, "Error [1:15]: moving_average: non-numeric element at index 1, got STRING_OBJ."},
+		{`moving_average(1, 1)`, "Error [1:15]: moving_average: invalid types provided: (INTEGER, INTEGER). Want (ARRAY, INTEGER)."},
+	})
+
+	for _, elem := range testEval(t, `moving_average([1, 2, 3], 1)`).(*Array).elements {
+		if elem.Type() != FLOAT_OBJ {
+			t.Errorf("got %v of type %v, want FLOAT", elem.Inspect(), elem.Type())
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestClampArray(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`clamp_array([1, 5, 10], 2, 8)`, "[2, 5, 8]"},
+		{`clamp_array([1.5, 4.5, 9.5], 2, 8)`, "[2, 4.5, 8]"},
+		{`clamp_array([1, 3, 5], 1.5, 4.5)`, "[1.5, 3, 4.5]"},
+		{`[clamp_array([], 0, 1), clamp_array([3], 3, 3)]`, "[[], [3]]"},
+		{`clamp_array([1], 5, 2)`, "Error [1:12]: clamp_array: lower bound 5 is greater than upper bound 2."},
+		{`clamp_array([1, "a"], 0, 1)`, "Error [1:12]: clamp_array: non-numeric element at index 1, got STRING_OBJ."},
+		{`clamp_array(1, 0, 1)`, "Error [1:12]: clamp_array: first argument to clamp_array must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestArgminAndArgmax(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[argmin([3, 1, 2]), argmax([3, 1, 2])]`, "[1, 0]"},
+		{`[argmax([1, 2.5, 2]), argmin([1.5, 1, 2])]`, "[1, 1]"},
+		// Ties go to the first occurrence.
+		{`[argmin([1, 1, 0, 0]), argmax([5, 5])]`, "[2, 0]"},
+		{`[argmin([]), argmax([])]`, "[-1, -1]"},
+		{`argmin([1, "a"])`, "Error [1:7]: argmin: non-numeric element at index 1, got STRING_OBJ."},
+		{`argmax(1)`, "Error [1:7]: argmax: argument to argmax must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestPowerSet(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[power_set([]), power_set([1])]`, "[[[]], [[], [1]]]"},
+		{`power_set([1, 2])`, "[[], [1], [2], [1, 2]]"},
+		{`slice(power_set([1, 2, 3]), 3, 8)`, "[[3], [1, 2], [1, 3], [2, 3], [1, 2, 3]]"},
+		{`[len(power_set([1, 2])), len(power_set([1, 2, 3])), len(power_set(range(0, 10)))]`, "[4, 8, 1024]"},
+		{`power_set(range(0, 21))`, "Error [1:10]: power_set: array has 21 elements, the limit is 20."},
+		{`power_set(1)`, "Error [1:10]: power_set: argument to power_set must be an Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// String builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestSqueezeSpaces(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{"squeeze_spaces(\"a   b\t c\")", "a b c"},
+		{"squeeze_spaces(\"  \t lead and trail \n \")", "lead and trail"},
+		{"squeeze_spaces(\"a\t\t\tb\")", "a b"},
+		// Unicode whitespace counts too.
+		{"squeeze_spaces(\"a 　b\")", "a b"},
+		{`squeeze_spaces("")`, ""},
+		{`squeeze_spaces(1)`, "Error [1:15]: squeeze_spaces: argument to squeeze_spaces must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFormat(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`format("{} + {} = {}", 1, 2, 3)`, "1 + 2 = 3"},
+		{`format("%v and {}", "a", [1, 2])`, "a and [1, 2]"},
+		{`format("none")`, "none"},
+		{`format("{} {}", 1)`, "Error [1:7]: format: template has 2 placeholders but 1 arguments were given."},
+		{`format("{}", 1, 2)`, "Error [1:7]: format: template has 1 placeholders but 2 arguments were given."},
+		{`format(1)`, "Error [1:7]: format: first argument to format must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestEditDistance(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`edit_distance("kitten", "sitting")`, "3"},
+		{`edit_distance("abc", "abc")`, "0"},
+		{`edit_distance("abc", "abd")`, "1"},
+		{`[edit_distance("abc", "abcd"), edit_distance("abcd", "acd")]`, "[1, 1]"},
+		{`[edit_distance("", "abc"), edit_distance("abc", "")]`, "[3, 3]"},
+		// Distances are counted in runes, not bytes.
+		{`edit_distance("héllo", "hello")`, "1"},
+		{`edit_distance("a", 1)`, "Error [1:14]: edit_distance: invalid types provided: (STRING_OBJ, INTEGER). Want (STRING, STRING)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestUpperLowerTrim(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ABC")`, "abc"},
+		{"trim(\"  hi \t\")", "hi"},
+		{`trim("xxhixx", "x")`, "hi"},
+		{`upper(1)`, "Error [1:6]: upper: argument to upper must be a String, got INTEGER."},
+		{`trim("a", 1)`, "Error [1:5]: trim: arguments to trim must be Strings, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCapitalizeAndTitle(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`capitalize("hello world")`, "Hello world"},
+		{`title("hello world")`, "Hello World"},
+		{`capitalize("  hello")`, "  Hello"},
+		{`title("  hello   big world")`, "  Hello   Big World"},
+		{`[capitalize("Hello"), title("HELLO wOrld")]`, "[Hello, HELLO WOrld]"},
+		{`[capitalize("élan"), title("élan vital")]`, "[Élan, Élan Vital]"},
+		{`capitalize("")`, ""},
+		{`capitalize(1)`, "Error [1:11]: capitalize: argument to capitalize must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestReplace(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`replace("banana", "a", "o")`, "bonono"},
+		{`replace("banana", "a", "o", 2)`, "bonona"},
+		{`[replace("banana", "x", "o"), replace("aaa", "aa", "b")]`, "[banana, ba]"},
+		// An empty old string matches around every rune, like strings.Replace.
+		{`[replace("abc", "", "-"), replace("abc", "", "-", 2)]`, "[-a-b-c-, -a-bc]"},
+		// A zero count replaces nothing and a negative one replaces everything.
+		{`[replace("banana", "a", "o", 0), replace("banana", "a", "o", -1)]`, "[banana, bonono]"},
+		{`replace("a", 1, "b")`, "Error [1:8]: replace: first three arguments to replace must be Strings, got INTEGER."},
+		{`replace("a", "b", "c", "d")`, "Error [1:8]: replace: count argument to replace must be an Integer, got STRING_OBJ."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCountSubstr(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[count_substr("banana", "a"), count_substr("banana", "an")]`, "[3, 2]"},
+		// Matches don't overlap.
+		{`count_substr("aaaa", "aa")`, "2"},
+		{`count_substr("banana", "x")`, "0"},
+		// An empty needle counts the gaps around each rune.
+		{`[count_substr("héllo", ""), count_substr("", "")]`, "[6, 1]"},
+		{`count_substr(1, "a")`, "Error [1:13]: count_substr: invalid types provided: (INTEGER, STRING_OBJ). Want (STRING, STRING)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestChrAndOrd(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[ord("A"), ord("é"), ord("😀")]`, "[65, 233, 128512]"},
+		{`[chr(65), chr(233), chr(128512)]`, "[A, é, 😀]"},
+		{`chr(ord("a") + 1)`, "b"},
+		{`ord("ab")`, "Error [1:4]: ord: expected a single character, got a string of length 2."},
+		{`ord("")`, "Error [1:4]: ord: expected a single character, got a string of length 0."},
+		{`ord(1)`, "Error [1:4]: ord: argument to ord must be a String, got INTEGER."},
+		{`chr(-1)`, "Error [1:4]: chr: -1 is not a valid code point."},
+		{`chr(1114112)`, "Error [1:4]: chr: 1114112 is not a valid code point."},
+		// Surrogate halves aren't characters on their own.
+		{`chr(55296)`, "Error [1:4]: chr: 55296 is not a valid code point."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestChunkString(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`chunk_string("abcdef", 2)`, "[ab, cd, ef]"},
+		{`chunk_string("abcde", 2)`, "[ab, cd, e]"},
+		{`chunk_string("héllo", 2)`, "[hé, ll, o]"},
+		{`[chunk_string("", 3), chunk_string("ab", 5)]`, "[[], [ab]]"},
+		{`chunk_string("ab", 0)`, "Error [1:13]: chunk_string: chunk size must be positive, got 0."},
+		{`chunk_string(1, 2)`, "Error [1:13]: chunk_string: invalid types provided: (INTEGER, INTEGER). Want (STRING, INTEGER)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCaseConversions(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[to_camel("foo_bar"), to_snake("fooBar"), to_kebab("fooBar")]`, "[fooBar, foo_bar, foo-bar]"},
+		{`[to_camel("foo-bar baz"), to_snake("FooBarBaz"), to_kebab("foo_bar")]`, "[fooBarBaz, foo_bar_baz, foo-bar]"},
+		// Runs of separators and leading or trailing ones are dropped.
+		{`[to_camel("__foo__bar__"), to_snake("--foo--bar--"), to_kebab("  foo  bar ")]`, "[fooBar, foo_bar, foo-bar]"},
+		// Acronyms stay together.
+		{`[to_kebab("HTTPServer"), to_snake("parseJSONValue"), to_snake("userID")]`, "[http-server, parse_json_value, user_id]"},
+		{`[to_snake(to_camel("foo_bar_baz")), to_camel(to_kebab("fooBarBaz")), to_kebab(to_snake("fooBar"))]`, "[foo_bar_baz, fooBarBaz, foo-bar]"},
+		{`[to_camel(""), to_snake("")]`, "[, ]"},
+		{`to_camel(1)`, "Error [1:9]: to_camel: argument to to_camel must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestRepeat(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat([1, 2], 2)`, "[1, 2, 1, 2]"},
+		// A count of zero gives an empty result, a negative one is an error.
+		{`repeat("ab", 0) == ""`, "true"},
+		{`repeat([1], 0)`, "[]"},
+		{`repeat("ab", -1)`, "Error [1:7]: repeat: count must not be negative, got -1."},
+		{`repeat([1], -1)`, "Error [1:7]: repeat: count must not be negative, got -1."},
+		{`repeat("ab", 9223372036854775807)`, "Error [1:7]: repeat: result would be longer than 16777216."},
+		{`repeat("ab", "x")`, "Error [1:7]: repeat: count must be an INTEGER, got STRING_OBJ."},
+		{`repeat(1, 2)`, "Error [1:7]: repeat: first argument to repeat must be a String or Array, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Number builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestDigitsAndFromDigits(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`digits(12345)`, "[1, 2, 3, 4, 5]"},
+		{`digits(0)`, "[0]"},
+		// The sign is dropped.
+		{`digits(-907)`, "[9, 0, 7]"},
+		{`let d = digits(-9223372036854775807 - 1); [len(d), last(d)]`, "[19, 8]"},
+		{`[from_digits([1, 2, 3]), from_digits([0, 4]), from_digits([])]`, "[123, 4, 0]"},
+		{`from_digits(digits(9876543210))`, "9876543210"},
+		{`from_digits([9, 2, 2, 3, 3, 7, 2, 0, 3, 6, 8, 5, 4, 7, 7, 5, 8, 0, 7])`, "9223372036854775807"},
+		{`from_digits([9, 2, 2, 3, 3, 7, 2, 0, 3, 6, 8, 5, 4, 7, 7, 5, 8, 0, 8])`, "Error [1:12]: from_digits: result overflows an Integer."},
+		{`from_digits(map(range(0, 20), fn(x) { 9 }))`, "Error [1:12]: from_digits: result overflows an Integer."},
+		{`from_digits([1, 10])`, "Error [1:12]: from_digits: element at index 1 is not a single digit Integer: 10."},
+		{`from_digits([1, -1])`, "Error [1:12]: from_digits: element at index 1 is not a single digit Integer: -1."},
+		{`digits("1")`, "Error [1:7]: digits: argument to digits must be an Integer, got STRING_OBJ."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestPrimes(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[is_prime(0), is_prime(1), is_prime(2), is_prime(3), is_prime(4)]`, "[false, false, true, true, false]"},
+		{`[is_prime(17), is_prime(21), is_prime(7919), is_prime(7917), is_prime(-7)]`, "[true, false, true, false, false]"},
+		{`[primes_up_to(-5), primes_up_to(1), primes_up_to(2), primes_up_to(3)]`, "[[], [], [2], [2, 3]]"},
+		{`slice(primes_up_to(30), 5, 10)`, "[13, 17, 19, 23, 29]"},
+		{`len(primes_up_to(100))`, "25"},
+		{`to_json(filter(range(0, 100), is_prime)) == to_json(primes_up_to(99))`, "true"},
+		{`primes_up_to(9223372036854775807)`, "Error [1:13]: primes_up_to: limit 9223372036854775807 is too large, the maximum is 67108864."},
+		{`is_prime(1.5)`, "Error [1:9]: is_prime: argument to is_prime must be an Integer, got FLOAT."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFactorialAndChoose(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[factorial(0), factorial(1), factorial(5)]`, "[1, 1, 120]"},
+		{`factorial(20)`, "2432902008176640000"},
+		{`factorial(21)`, "Error [1:10]: factorial: result of factorial(21) overflows an Integer."},
+		{`factorial(-1)`, "Error [1:10]: factorial: argument must be non-negative, got -1."},
+		{`factorial(1.0)`, "Error [1:10]: factorial: argument to factorial must be an Integer, got FLOAT."},
+		{`[choose(5, 2), choose(5, 0), choose(5, 5), choose(0, 0)]`, "[10, 1, 1, 1]"},
+		{`[choose(3, 5), choose(0, 1)]`, "[0, 0]"},
+		{`choose(62, 31)`, "465428353255261088"},
+		{`choose(67, 33)`, "Error [1:7]: choose: result of choose(67, 33) overflows an Integer."},
+		{`choose(-1, 2)`, "Error [1:7]: choose: arguments must be non-negative, got (-1, 2)."},
+		{`choose(5, -1)`, "Error [1:7]: choose: arguments must be non-negative, got (5, -1)."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestAbsAndSign(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[abs(-5), abs(0), abs(5)]`, "[5, 0, 5]"},
+		{`[abs(-2.5), abs(0.0), abs(2.5)]`, "[2.5, 0, 2.5]"},
+		{`[sign(-5), sign(0), sign(5)]`, "[-1, 0, 1]"},
+		{`[sign(-2.5), sign(0.0), sign(2.5)]`, "[-1, 0, 1]"},
+		{`abs(-9223372036854775807 - 1)`, "Error [1:4]: abs: result overflows an INTEGER."},
+		{`abs("a")`, "Error [1:4]: abs: expected an INTEGER or FLOAT, got STRING_OBJ."},
+		{`sign([1])`, "Error [1:5]: sign: expected an INTEGER or FLOAT, got ARRAY."},
+	})
+
+	// abs keeps the type of its argument, sign always gives an Integer.
+	cases := []struct {
+		input    string
+		expected ObjectType
+	}{
+		{`abs(-5)`, INTEGER_OBJ},
+		{`abs(-2.5)`, FLOAT_OBJ},
+		{`sign(-2.5)`, INTEGER_OBJ},
+		{`sign(0.0)`, INTEGER_OBJ},
+	}
+	for _, tc := range cases {
+		if got := testEval(t, tc.input).Type(); got != tc.expected {
+			t.Errorf("%q: got %v, want %v", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// JSON builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestToJSON(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[to_json(1), to_json(2.5), to_json(true), to_json(first([]))]`, "[1, 2.5, true, null]"},
+		{`[to_json([]), to_json({})]`, "[[], {}]"},
+		{`to_json({"a": [1, 2.5, {"b": first([])}], "c": "x"})`, `{"a":[1,2.5,{"b":null}],"c":"x"}`},
+		// Keys are written in insertion order.
+		{`to_json({"b": 1, "a": 2})`, `{"b":1,"a":2}`},
+		{"to_json(`a\"b\\c`)", `"a\"b\\c"`},
+		{"to_json(\"tab\tnew\nline\")", `"tab\tnew\nline"`},
+		{`to_json({1: 2})`, "Error [1:8]: to_json: hash keys must be Strings, got INTEGER."},
+		{`to_json(fn(x) { x })`, "Error [1:8]: to_json: FUNCTION is not serializable."},
+		{`to_json([1, [len]])`, "Error [1:8]: to_json: BUILTIN is not serializable."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestFromJSON(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{"from_json(`{\"a\": [1, 2.5, true, null], \"b\": \"x\"}`)", "{a: [1, 2.5, true, null], b: x}"},
+		{"[from_json(\"1e3\"), from_json(\"-0\"), from_json(`\"s\"`), from_json(\"[]\")]", "[1000, 0, s, []]"},
+		{"from_json(`\"tab\\t\\u00e9\"`)", "tab\té"},
+		// Objects come back with their keys sorted, and the last duplicate wins.
+		{"keys(from_json(`{\"b\": 1, \"a\": 2}`))", "[a, b]"},
+		{"from_json(`{\"a\": 1, \"a\": 2}`)", "{a: 2}"},
+		{"to_json(from_json(`{\"a\": [1, {\"c\": null}], \"b\": 2.5}`))", `{"a":[1,{"c":null}],"b":2.5}`},
+		{`from_json("99999999999999999999")`, "1e+20"},
+		{`from_json("{")`, "Error [1:10]: from_json: invalid JSON: unexpected EOF."},
+		{`from_json("")`, "Error [1:10]: from_json: invalid JSON: EOF."},
+		{`from_json("[1, 2] x")`, "Error [1:10]: from_json: invalid JSON: unexpected data after top-level value."},
+		{`from_json(1)`, "Error [1:10]: from_json: argument to from_json must be a String, got INTEGER."},
+	})
+
+	// Whole floats inspect like integers, so check the number types directly.
+	cases := []struct {
+		input    string
+		expected ObjectType
+	}{
+		{`from_json("1")`, INTEGER_OBJ},
+		{`from_json("-7")`, INTEGER_OBJ},
+		{`from_json("1.0")`, FLOAT_OBJ},
+		{`from_json("1e3")`, FLOAT_OBJ},
+	}
+	for _, tc := range cases {
+		if got := testEval(t, tc.input).Type(); got != tc.expected {
+			t.Errorf("%q: got %v, want %v", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Benchmarks
+// --------------------------------------------------------------------------------------------------------------------
+
+// The recursive Monkey versions map, filter and reduce had before they became native builtins.
+const selfHostedHigherOrder = `
+let old_map = fn(arr, function) {
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) { acc } else { iter(rest(arr), push(acc, function(first(arr)))) }
+	}
+	iter(arr, [])
+}
+let old_filter = fn(arr, function) {
+	let iter = fn(arr, acc) {
+		if (len(arr) == 0) {
+			acc
+		} else {
+			let working = first(arr)
+			if (function(working)) { iter(rest(arr), push(acc, working)) } else { iter(rest(arr), acc) }
+		}
+	}
+	iter(arr, [])
+}
+let old_reduce = fn(arr, initial, function) {
+	let iter = fn(arr, result) {
+		if (len(arr) == 0) { result } else { iter(rest(arr), function(result, first(arr))) }
+	}
+	iter(arr, initial)
+}
+let arr = range(0, 10000)
+let double = fn(x) { x * 2 }
+let even = fn(x) { x % 2 == 0 }
+let add = fn(acc, x) { acc + x }
+`
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func benchmarkSource(b *testing.B, src string) {
+	interp := New()
+	interp.SetOutput(io.Discard)
+	if _, err := interp.Run(selfHostedHigherOrder); err != nil {
+		b.Fatalf("setup failed: %v", err)
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := interp.Run(src); err != nil {
+			b.Fatalf("%v failed: %v", src, err)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func BenchmarkMap(b *testing.B) {
+	b.Run("native", func(b *testing.B) { benchmarkSource(b, `map(arr, double)`) })
+	b.Run("self-hosted", func(b *testing.B) { benchmarkSource(b, `old_map(arr, double)`) })
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func BenchmarkFilter(b *testing.B) {
+	b.Run("native", func(b *testing.B) { benchmarkSource(b, `filter(arr, even)`) })
+	b.Run("self-hosted", func(b *testing.B) { benchmarkSource(b, `old_filter(arr, even)`) })
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func BenchmarkReduce(b *testing.B) {
+	b.Run("native", func(b *testing.B) { benchmarkSource(b, `reduce(arr, 0, add)`) })
+	b.Run("self-hosted", func(b *testing.B) { benchmarkSource(b, `old_reduce(arr, 0, add)`) })
+}
+
+// --------------------------------------------------------------------------------------------------------------------
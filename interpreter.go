@@ -0,0 +1,138 @@
+package monkey
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Embedding api
+// --------------------------------------------------------------------------------------------------------------------
+
+// Settings and evaluation state live here rather than in package globals, so interpreters don't affect each other.
+// A single interpreter is not safe for concurrent use.
+type Interpreter struct {
+	env           *Environment
+	output        io.Writer
+	fileIOEnabled bool
+	maxCallDepth  int
+	callDepth     int
+	overloadDepth int
+	evalDepth     int
+	// Imported modules by absolute path, and the imports still being evaluated.
+	modules map[string]*Module
+	loading map[string]bool
+}
+
+type ParseError struct {
+	errors []string
+}
+
+func (p *ParseError) Error() string { return strings.Join(p.errors, "\n") }
+
+func (p *ParseError) Errors() []string { return p.errors }
+
+// Returned by Run when the program calls exit. The process is left running, acting on Code is up to the host.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string { return fmt.Sprintf("exit status %v", e.Code) }
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func New() *Interpreter {
+	interp := &Interpreter{
+		output:       os.Stdout,
+		maxCallDepth: DEFAULT_MAX_CALL_DEPTH,
+		modules:      make(map[string]*Module),
+		loading:      make(map[string]bool),
+	}
+	interp.env = newEnvironment(interp)
+	loadNativeBuiltins(interp.env)
+
+	return interp
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (i *Interpreter) Env() *Environment {
+	return i.env
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (i *Interpreter) SetMaxCallDepth(depth int) {
+	i.maxCallDepth = depth
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (i *Interpreter) EnableFileIO(enabled bool) {
+	i.fileIOEnabled = enabled
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Output from puts and print goes to stdout unless redirected here.
+func (i *Interpreter) SetOutput(w io.Writer) {
+	i.output = w
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (i *Interpreter) Output() io.Writer {
+	return i.output
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Parse failures give a *ParseError and no object. Runtime failures return the Error object alongside a Go error,
+// and calling exit gives an *ExitError.
+func (i *Interpreter) Run(src string) (Object, error) {
+	lexer := newLexer(src)
+	parser := newParser(lexer)
+	program := parser.parseProgram()
+
+	if len(parser.errors) != 0 {
+		i.recordError(strings.Join(parser.errors, "\n"), 0, 0)
+		return nil, &ParseError{errors: parser.errors}
+	}
+
+	evaluated := eval(program, i.env)
+	if exit, ok := evaluated.(*Exit); ok {
+		return &NullObject, &ExitError{Code: exit.code}
+	}
+	if err, ok := evaluated.(*Error); ok {
+		i.recordError(err.message, err.line, err.column)
+		return evaluated, errors.New(evaluated.Inspect())
+	}
+
+	return evaluated, nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Errors are kept as a plain hash, since handing back the Error itself would abort whatever called last_error. Parse
+// errors have no single position, so their line and column are 0.
+func (i *Interpreter) recordError(message string, line, column int) {
+	record := newHash()
+	for _, field := range []struct {
+		name  string
+		value Object
+	}{
+		{"message", &StringValue{value: message}},
+		{"line", &Integer{value: int64(line)}},
+		{"column", &Integer{value: int64(column)}},
+	} {
+		key := &StringValue{value: field.name}
+		record.set(key.HashKey(), HashPair{key: key, value: field.value})
+	}
+
+	i.env.Set(LAST_ERROR_SLOT, record)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
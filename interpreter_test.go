@@ -0,0 +1,122 @@
+package monkey
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Embedding api
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestSetOutputCapturesPutsAndPrint(t *testing.T) {
+	var buffer bytes.Buffer
+	interp := New()
+	interp.SetOutput(&buffer)
+
+	if _, err := interp.Run(`puts(1, "a"); print("x", 2); puts([1, 2])`); err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+
+	if got, want := buffer.String(), "1\na\nx2[1, 2]\n"; got != want {
+		t.Errorf("got output %q, want %q", got, want)
+	}
+	if interp.Output() != &buffer {
+		t.Errorf("Output did not return the writer passed to SetOutput")
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestInterpretersDoNotShareSettings(t *testing.T) {
+	var first, second bytes.Buffer
+	a := New()
+	a.SetOutput(&first)
+	a.SetMaxCallDepth(10)
+	b := New()
+	b.SetOutput(&second)
+
+	src := `let count = fn(n) { if (n == 0) { 0 } else { 1 + count(n - 1) } }; puts(count(50))`
+	if _, err := a.Run(src); err == nil {
+		t.Errorf("expected the first interpreter to hit its call depth limit")
+	}
+	if _, err := b.Run(src); err != nil {
+		t.Errorf("second interpreter failed: %v", err)
+	}
+
+	if first.String() != "" || second.String() != "50\n" {
+		t.Errorf("got outputs %q and %q", first.String(), second.String())
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestInterpretersRunConcurrently(t *testing.T) {
+	var wg sync.WaitGroup
+	buffers := make([]bytes.Buffer, 8)
+
+	for idx := range buffers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			interp := New()
+			interp.SetOutput(&buffers[idx])
+			interp.Run(`let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(200); puts(eval("1 + 1"))`)
+		}()
+	}
+	wg.Wait()
+
+	for idx := range buffers {
+		if got := buffers[idx].String(); got != "2\n" {
+			t.Errorf("interpreter %v: got output %q", idx, got)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestExitReturnsToTheHost(t *testing.T) {
+	cases := []struct {
+		input  string
+		code   int
+		output string
+	}{
+		{`puts("before"); exit(3); puts("after")`, 3, "before\n"},
+		{`exit()`, 0, ""},
+		// Exit unwinds out of functions, loops, callbacks and default parameters.
+		{`let f = fn() { each([1, 2], fn(x) { puts(x); exit(x + 4) }) }; f(); puts("after")`, 5, "1\n"},
+		{`for (x in [1, 2, 3]) { if (x == 2) { exit(x) } puts(x) }`, 2, "1\n"},
+		{`let f = fn(a = exit(7)) { puts("body") }; f()`, 7, ""},
+		{`eval("exit(8)"); puts("after")`, 8, ""},
+	}
+
+	for _, tc := range cases {
+		var buffer bytes.Buffer
+		interp := New()
+		interp.SetOutput(&buffer)
+
+		_, err := interp.Run(tc.input)
+		exitErr, ok := err.(*ExitError)
+		if !ok {
+			t.Errorf("%q: got error %v, want an *ExitError", tc.input, err)
+			continue
+		}
+		if exitErr.Code != tc.code {
+			t.Errorf("%q: got code %v, want %v", tc.input, exitErr.Code, tc.code)
+		}
+		if got := buffer.String(); got != tc.output {
+			t.Errorf("%q: got output %q, want %q", tc.input, got, tc.output)
+		}
+	}
+
+	// The interpreter stays usable, and a bad argument is a plain error.
+	interp := New()
+	interp.Run(`exit(1)`)
+	result, err := interp.Run(`exit("a")`)
+	if _, ok := err.(*ExitError); ok || result.Inspect() != "Error [1:5]: exit: argument to exit must be an INTEGER, got STRING_OBJ." {
+		t.Errorf("exit with a string: got %v, %v", result.Inspect(), err)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
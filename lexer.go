@@ -1,8 +1,10 @@
-package main
+package monkey
 
 import (
 	"fmt"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 const END = 0
@@ -14,7 +16,11 @@ const END = 0
 type Lexer struct {
 	input                           string
 	idx, peek, line, column, length int
-	ch                              byte
+	lastLine, lastColumn            int
+	ch                              rune
+	// The previous token decides whether a newline ends a statement. The token after an implicit semicolon waits in pending.
+	lastType TokenType
+	pending  *Token
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -31,8 +37,35 @@ func newLexer(input string) *Lexer {
 // --------------------------------------------------------------------------------------------------------------------
 
 func (l *Lexer) nextToken() Token {
-	l.skipWhitespace()
+	if l.pending != nil {
+		tok := *l.pending
+		l.pending = nil
+		l.lastType = tok.tokenType
+		return tok
+	}
+
+	line := l.line
+	col := l.column
+	crossedNewline := l.skipWhitespace()
+
+	tok := l.readToken()
+	tok.endLine = l.lastLine
+	tok.endColumn = l.lastColumn
+
+	// Like Go, a line break after a token that can end a statement acts as a semicolon.
+	if crossedNewline && endsStatement(l.lastType) && !continuesStatement(tok.tokenType) {
+		l.pending = &tok
+		l.lastType = SEMICOLON
+		return Token{tokenType: SEMICOLON, literal: ";", line: line, column: col, endLine: line, endColumn: col}
+	}
+	l.lastType = tok.tokenType
+
+	return tok
+}
 
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) readToken() Token {
 	switch l.ch {
 	case '=':
 		return l.makeTwoCharToken(ASSIGN, EQ)
@@ -43,9 +76,11 @@ func (l *Lexer) nextToken() Token {
 	case '<':
 		return l.makeTwoCharToken(LT, LTEQ)
 	case '+':
-		return l.makeToken(PLUS)
+		return l.makeDoubledToken(PLUS, INCREMENT)
 	case '-':
-		return l.makeToken(MINUS)
+		return l.makeDoubledToken(MINUS, DECREMENT)
+	case '?':
+		return l.lexQuestion()
 	case '*':
 		return l.makeToken(ASTERIX)
 	case '%':
@@ -70,8 +105,14 @@ func (l *Lexer) nextToken() Token {
 		return l.makeToken(LBRACKET)
 	case ']':
 		return l.makeToken(RBRACKET)
+	case '.':
+		return l.lexDot()
 	case '"':
 		return l.lexString()
+	case '\'':
+		return l.lexChar()
+	case '`':
+		return l.lexRawString()
 	case END:
 		return l.makeToken(EOF)
 	default:
@@ -82,7 +123,7 @@ func (l *Lexer) nextToken() Token {
 // --------------------------------------------------------------------------------------------------------------------
 
 func (l *Lexer) lexOther() Token {
-	if l.isDigit() {
+	if l.startsNumber() {
 		return l.lexNumber()
 	}
 	if l.isLetter() {
@@ -94,10 +135,55 @@ func (l *Lexer) lexOther() Token {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func (l *Lexer) lexDot() Token {
+	if l.peek+1 < l.length && l.input[l.peek] == '.' && l.input[l.peek+1] == '.' {
+		line := l.line
+		col := l.column
+		l.readChar()
+		l.readChar()
+		l.readChar()
+		return Token{tokenType: ELLIPSIS, literal: "...", line: line, column: col}
+	}
+	if l.peek < l.length && '0' <= l.input[l.peek] && l.input[l.peek] <= '9' {
+		return l.lexNumber()
+	}
+
+	return l.makeToken(DOT)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) lexQuestion() Token {
+	if l.peek >= l.length {
+		return l.makeToken(ILLEGAL)
+	}
+
+	var tokenType TokenType
+	switch l.input[l.peek] {
+	case '?':
+		tokenType = COALESCE
+	case '.':
+		tokenType = OPTIONAL_DOT
+	case '[':
+		tokenType = OPTIONAL_LBRACKET
+	default:
+		return l.makeToken(ILLEGAL)
+	}
+
+	line := l.line
+	col := l.column
+	l.readChar()
+	l.readChar()
+
+	return Token{tokenType: tokenType, literal: string(tokenType), line: line, column: col}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (l *Lexer) lexIdentKeyword() Token {
 	line := l.line
 	col := l.column
-	literal := l.readLiteral(l.isLetter)
+	literal := l.readLiteral(l.isAlphanumeric)
 
 	return Token{tokenType: lookupIdent(literal), literal: literal, line: line, column: col}
 }
@@ -105,15 +191,54 @@ func (l *Lexer) lexIdentKeyword() Token {
 // --------------------------------------------------------------------------------------------------------------------
 
 func (l *Lexer) lexNumber() Token {
+	if l.isRadixPrefix() {
+		return l.lexRadixNumber()
+	}
+
 	line := l.line
 	col := l.column
-	literal := l.readLiteral(l.isDigit)
+	start := l.idx
+	var tokenType TokenType = INT
+
+	l.readLiteral(l.isDigit)
+	if l.ch == '.' {
+		tokenType = FLOAT
+		l.readChar()
+		l.readLiteral(l.isDigit)
+	}
+
+	if l.ch == 'e' || l.ch == 'E' {
+		tokenType = FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readLiteral(l.isDigit)
+	}
 
-	if strings.Contains(literal, ".") {
-		return Token{tokenType: FLOAT, literal: literal, line: line, column: col}
+	literal := l.input[start:l.idx]
+	if l.ch == '.' || strings.HasPrefix(literal, ".") || strings.HasSuffix(literal, ".") {
+		for l.isDigit() || l.ch == '.' {
+			l.readChar()
+		}
+		return Token{tokenType: ILLEGAL, literal: l.input[start:l.idx], line: line, column: col}
 	}
 
-	return Token{tokenType: INT, literal: literal, line: line, column: col}
+	return Token{tokenType: tokenType, literal: literal, line: line, column: col}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) lexRadixNumber() Token {
+	line := l.line
+	col := l.column
+	start := l.idx
+
+	l.readChar()
+	l.readChar()
+	l.readLiteral(l.isAlphanumeric)
+
+	return Token{tokenType: INT, literal: l.input[start:l.idx], line: line, column: col}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -121,7 +246,7 @@ func (l *Lexer) lexNumber() Token {
 func (l *Lexer) lexString() Token {
 	line := l.line
 	col := l.column
-	literal := l.readString()
+	literal := l.readString('"')
 	tok := Token{tokenType: STRING, literal: literal, line: line, column: col}
 	l.readChar()
 
@@ -130,6 +255,54 @@ func (l *Lexer) lexString() Token {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// Raw strings keep their contents exactly as written, newlines included.
+func (l *Lexer) lexRawString() Token {
+	line := l.line
+	col := l.column
+	start := l.peek
+	literal := l.readString('`')
+
+	if l.ch == END {
+		return Token{tokenType: ILLEGAL, literal: l.input[start-1:], line: line, column: col}
+	}
+
+	tok := Token{tokenType: RAW_STRING, literal: literal, line: line, column: col}
+	l.readChar()
+
+	return tok
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// The literal is everything between the quotes, escapes are resolved by the parser.
+func (l *Lexer) lexChar() Token {
+	line := l.line
+	col := l.column
+	start := l.peek
+
+	l.readChar()
+	for l.ch != '\'' && l.ch != END {
+		if l.ch == '\\' {
+			l.readChar()
+			if l.ch == END {
+				break
+			}
+		}
+		l.readChar()
+	}
+
+	if l.ch == END {
+		return Token{tokenType: ILLEGAL, literal: l.input[start-1 : min(l.idx, l.length)], line: line, column: col}
+	}
+
+	tok := Token{tokenType: CHAR, literal: l.input[start:l.idx], line: line, column: col}
+	l.readChar()
+
+	return tok
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (l *Lexer) makeToken(tokenType TokenType) Token {
 	tok := Token{tokenType: tokenType, literal: string(l.ch), line: l.line, column: l.column}
 	l.readChar()
@@ -153,18 +326,73 @@ func (l *Lexer) makeTwoCharToken(singleType, secondType TokenType) Token {
 	return l.makeToken(singleType)
 }
 
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) makeDoubledToken(singleType, doubledType TokenType) Token {
+	line := l.line
+	col := l.column
+	first := l.ch
+
+	if l.peek < l.length && rune(l.input[l.peek]) == first && l.touchesOperand() {
+		l.readChar()
+		l.readChar()
+		return Token{tokenType: doubledType, literal: string(first) + string(first), line: line, column: col}
+	}
+
+	return l.makeToken(singleType)
+}
+
 // --------------------------------------------------------------------------------------------------------------------
 // Helpers
 // --------------------------------------------------------------------------------------------------------------------
 
+// ++ and -- are postfix operators only when they touch a variable or index, so 5--3 still reads as 5 - -3.
+func (l *Lexer) touchesOperand() bool {
+	if l.lastType != IDENT && l.lastType != RBRACKET {
+		return false
+	}
+
+	return l.idx > 0 && !unicode.IsSpace(rune(l.input[l.idx-1]))
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (l *Lexer) isDigit() bool {
-	return '0' <= l.ch && l.ch <= '9' || l.ch == '.'
+	return '0' <= l.ch && l.ch <= '9' || l.ch == '_'
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) isAlphanumeric() bool {
+	return l.isLetter() || '0' <= l.ch && l.ch <= '9'
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) isRadixPrefix() bool {
+	if l.ch != '0' || l.peek >= l.length {
+		return false
+	}
+
+	switch l.input[l.peek] {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	default:
+		return false
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (l *Lexer) startsNumber() bool {
+	return '0' <= l.ch && l.ch <= '9'
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
 func (l *Lexer) isLetter() bool {
-	return 'a' <= l.ch && l.ch <= 'z' || 'A' <= l.ch && l.ch <= 'Z' || l.ch == '_'
+	return 'a' <= l.ch && l.ch <= 'z' || 'A' <= l.ch && l.ch <= 'Z' || l.ch == '_' ||
+		l.ch >= utf8.RuneSelf && unicode.IsLetter(l.ch)
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -180,12 +408,12 @@ func (l *Lexer) readLiteral(breakCond func() bool) string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (l *Lexer) readString() string {
+func (l *Lexer) readString(terminator rune) string {
 	position := l.peek
 
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == END {
+		if l.ch == terminator || l.ch == END {
 			break
 		}
 	}
@@ -198,21 +426,27 @@ func (l *Lexer) readString() string {
 // --------------------------------------------------------------------------------------------------------------------
 
 func (l *Lexer) readChar() {
+	l.lastLine = l.line
+	l.lastColumn = l.column
+
+	l.idx = l.peek
 	if l.peek >= l.length {
 		l.ch = END
+		l.peek += 1
 	} else {
-		l.ch = l.input[l.peek]
+		// Decode a whole rune so multibyte characters take up a single column.
+		ch, width := utf8.DecodeRuneInString(l.input[l.peek:])
+		l.ch = ch
+		l.peek += width
 	}
-
-	l.idx = l.peek
-	l.peek += 1
 	l.setLineCol()
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// A newline belongs to the line it ends, so the line only advances for the character after it.
 func (l *Lexer) setLineCol() {
-	if l.ch == '\n' {
+	if l.idx > 0 && l.idx <= l.length && l.input[l.idx-1] == '\n' {
 		l.line += 1
 		l.column = 1
 	} else {
@@ -222,15 +456,46 @@ func (l *Lexer) setLineCol() {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (l *Lexer) skipWhitespace() {
+func (l *Lexer) skipWhitespace() bool {
+	crossedNewline := false
+
 	for {
 		switch l.ch {
-		case '\n', ' ', '\t', '\r':
+		case '\n':
+			crossedNewline = true
+			l.readChar()
+		case ' ', '\t', '\r':
 			l.readChar()
 		default:
-			return
+			return crossedNewline
 		}
 	}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
+
+func endsStatement(tokenType TokenType) bool {
+	switch tokenType {
+	case IDENT, INT, FLOAT, STRING, RAW_STRING, CHAR, TRUE, FALSE, RPAREN, RBRACKET, RBRACE, INCREMENT, DECREMENT:
+		return true
+	default:
+		return false
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Tokens that can't start a statement carry the previous line on, so closing brackets, else, method chains and
+// leading binary operators all continue it. Minus and bang could start a new statement, so they don't.
+func continuesStatement(tokenType TokenType) bool {
+	switch tokenType {
+	case SEMICOLON, RPAREN, RBRACKET, RBRACE, LBRACE, ELSE, WHILE, DOT, OPTIONAL_DOT, EOF:
+		return true
+	case PLUS, ASTERIX, SLASH, MODULO, EQ, NOTEQ, LT, GT, LTEQ, GTEQ, COALESCE, COMMA, COLON, IN:
+		return true
+	default:
+		return false
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
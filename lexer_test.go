@@ -0,0 +1,162 @@
+package monkey
+
+import (
+	"strings"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------------------------------------------
+
+// Joins the literals of every token before EOF with spaces.
+func lexLiterals(input string) string {
+	lexer := newLexer(input)
+	literals := make([]string, 0)
+
+	for tok := lexer.nextToken(); tok.tokenType != EOF; tok = lexer.nextToken() {
+		literals = append(literals, tok.literal)
+	}
+
+	return strings.Join(literals, " ")
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Implicit semicolons
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestImplicitSemicolons(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = 1; let b = 2;", "let a = 1 ; let b = 2 ;"},
+		{"let a = 1\nlet b = 2", "let a = 1 ; let b = 2"},
+		{"let a = 1;\nlet b = 2;\n", "let a = 1 ; let b = 2 ;"},
+		{"a\n\n\nb", "a ; b"},
+		{"x++\ny--\n", "x ++ ; y --"},
+		{"f(1)\n[1]", "f ( 1 ) ; [ 1 ]"},
+		{"if (x)\n{ 1 }", "if ( x ) { 1 }"},
+		// Trailing operators and commas carry the statement onto the next line.
+		{"let a = 1 +\n2", "let a = 1 + 2"},
+		{"f(1,\n2)", "f ( 1 , 2 )"},
+		{"let a =\n1", "let a = 1"},
+		// So do tokens that can't start a statement.
+		{"let a = 1\n+ 2", "let a = 1 + 2"},
+		{"obj\n.method()", "obj . method ( )"},
+		{"[1,\n2\n]", "[ 1 , 2 ]"},
+		{"if (x) { 1 }\nelse { 2 }", "if ( x ) { 1 } else { 2 }"},
+		{"do { x }\nwhile (y)", "do { x } while ( y )"},
+		// Minus and bang could start a new statement.
+		{"a\n-1", "a ; - 1"},
+		{"a\n!b", "a ; ! b"},
+	}
+
+	for _, tc := range cases {
+		if got := lexLiterals(tc.input); got != tc.expected {
+			t.Errorf("%q: got %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Postfix operators
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestIncrementNeedsAnOperand(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"x++", "x ++"},
+		{"a[0]--", "a [ 0 ] --"},
+		// Anywhere else a doubled sign is two operators.
+		{"5--3", "5 - - 3"},
+		{"a - -b", "a - - b"},
+		{"a --b", "a - - b"},
+		{"f()--1", "f ( ) - - 1"},
+		{"--x", "- - x"},
+	}
+
+	for _, tc := range cases {
+		if got := lexLiterals(tc.input); got != tc.expected {
+			t.Errorf("%q: got %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+
+	runEvalCases(t, []evalCase{
+		{`5--3`, "8"},
+		{`let a = 5; let b = 3; a - -b`, "8"},
+		{`let a = 5; a--; a`, "4"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Positions
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestTokenPositions(t *testing.T) {
+	input := "let abc = 10;\nabc >= \"hi\"\n  fn(a) { a.len() }"
+	expected := []struct {
+		literal      string
+		line, column int
+	}{
+		{"let", 1, 1}, {"abc", 1, 5}, {"=", 1, 9}, {"10", 1, 11}, {";", 1, 13},
+		// Multi-character tokens are positioned at their first character, strings at the opening quote.
+		{"abc", 2, 1}, {">=", 2, 5}, {"hi", 2, 8},
+		// The implicit semicolon sits where the line break is.
+		{";", 2, 12},
+		{"fn", 3, 3}, {"(", 3, 5}, {"a", 3, 6}, {")", 3, 7}, {"{", 3, 9},
+		{"a", 3, 11}, {".", 3, 12}, {"len", 3, 13}, {"(", 3, 16}, {")", 3, 17}, {"}", 3, 19},
+	}
+
+	lexer := newLexer(input)
+	for _, want := range expected {
+		tok := lexer.nextToken()
+		if tok.literal != want.literal || tok.line != want.line || tok.column != want.column {
+			t.Errorf("got %q at [%v:%v], want %q at [%v:%v]", tok.literal, tok.line, tok.column, want.literal, want.line, want.column)
+		}
+	}
+	if tok := lexer.nextToken(); tok.tokenType != EOF {
+		t.Errorf("got %q, want EOF", tok.literal)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Identifiers
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"let café = naïve", "let café = naïve"},
+		{"名前 + π", "名前 + π"},
+		{"привет_мир(Ωmega2)", "привет_мир ( Ωmega2 )"},
+	}
+
+	for _, tc := range cases {
+		if got := lexLiterals(tc.input); got != tc.expected {
+			t.Errorf("%q: got %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+
+	// Multibyte letters take up a single column.
+	lexer := newLexer("let café = 1")
+	for range 2 {
+		lexer.nextToken()
+	}
+	if tok := lexer.nextToken(); tok.column != 10 {
+		t.Errorf("got %q at column %v, want column 10", tok.literal, tok.column)
+	}
+
+	runEvalCases(t, []evalCase{
+		{`let café = 1; let naïve = café + 1; naïve`, "2"},
+		{`let 名前 = "x"; let π = 3.14; [名前, π]`, "[x, 3.14]"},
+		{`let Ωmega_2 = fn(ä) { ä * 2 }; Ωmega_2(4)`, "8"},
+		{`let привет = 5; привет`, "5"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
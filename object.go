@@ -1,9 +1,11 @@
-package main
+package monkey
 
 import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"sort"
 	"strings"
 )
 
@@ -17,17 +19,20 @@ type Hashable interface {
 	HashKey() HashKey
 }
 
-type BuiltinFunc func(args ...Object) Object
+// Builtins receive the environment they are called from.
+type BuiltinFunc func(env *Environment, args ...Object) Object
 
 const (
 	ARRAY_OBJ    = "ARRAY"
 	BOOL_OBJ     = "BOOLEAN"
 	BUILTIN_OBJ  = "BUILTIN"
 	ERR_OBJ      = "ERROR_OBJ"
+	EXIT_OBJ     = "EXIT"
 	FLOAT_OBJ    = "FLOAT"
 	FUNCTION_OBJ = "FUNCTION"
 	HASH_OBJ     = "HASH_OBJ"
 	INTEGER_OBJ  = "INTEGER"
+	MODULE_OBJ   = "MODULE"
 	NULL_OBJ     = "NULL"
 	RETURN_OBJ   = "RETURN_VALUE"
 	STRING_OBJ   = "STRING_OBJ"
@@ -39,7 +44,7 @@ const (
 
 type Object interface {
 	Type() ObjectType
-	inspect() string
+	Inspect() string
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -52,12 +57,12 @@ type Array struct {
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
 
-func (a *Array) inspect() string {
+func (a *Array) Inspect() string {
 	var buffer bytes.Buffer
 	elements := make([]string, 0)
 
 	for idx, elem := range a.elements {
-		elements = append(elements, elem.inspect())
+		elements = append(elements, elem.Inspect())
 		if idx == 5 {
 			buffer.WriteString("[")
 			buffer.WriteString(strings.Join(elements, ", "))
@@ -83,7 +88,7 @@ type Boolean struct {
 
 func (b *Boolean) Type() ObjectType { return BOOL_OBJ }
 
-func (b *Boolean) inspect() string { return fmt.Sprintf("%v", b.value) }
+func (b *Boolean) Inspect() string { return fmt.Sprintf("%v", b.value) }
 
 func (b *Boolean) HashKey() HashKey {
 	var value uint64
@@ -104,17 +109,34 @@ type Builtin struct {
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 
-func (b *Builtin) inspect() string { return "builtin function" }
+func (b *Builtin) Inspect() string { return "builtin function" }
 
 // --------------------------------------------------------------------------------------------------------------------
 
 type Error struct {
-	message string
+	message      string
+	line, column int
 }
 
 func (e *Error) Type() ObjectType { return ERR_OBJ }
 
-func (e *Error) inspect() string { return "Error: " + e.message }
+func (e *Error) Inspect() string {
+	if e.line == 0 {
+		return "Error: " + e.message
+	}
+	return fmt.Sprintf("Error [%v:%v]: %v", e.line, e.column, e.message)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Returned by exit. It unwinds evaluation like an error, and Run hands the code to the host as an *ExitError.
+type Exit struct {
+	code int
+}
+
+func (e *Exit) Type() ObjectType { return EXIT_OBJ }
+
+func (e *Exit) Inspect() string { return fmt.Sprintf("exit(%v)", e.code) }
 
 // --------------------------------------------------------------------------------------------------------------------
 
@@ -124,23 +146,40 @@ type Float struct {
 
 func (f *Float) Type() ObjectType { return FLOAT_OBJ }
 
-func (f *Float) inspect() string { return fmt.Sprintf("%v", f.value) }
+func (f *Float) Inspect() string { return fmt.Sprintf("%v", f.value) }
+
+func (f *Float) HashKey() HashKey {
+	// -0.0 == 0.0, so both must land on the same key.
+	if f.value == 0 {
+		return HashKey{keyType: f.Type(), value: 0}
+	}
+	return HashKey{keyType: f.Type(), value: math.Float64bits(f.value)}
+}
 
 // --------------------------------------------------------------------------------------------------------------------
 
 type Function struct {
 	parameters []*Identifier
+	defaults   []Expression
+	rest       *Identifier
 	body       *BlockStatement
 	env        *Environment
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
 
-func (f *Function) inspect() string {
+func (f *Function) Inspect() string {
 	params := make([]string, 0)
 
-	for _, param := range f.parameters {
-		params = append(params, param.toString())
+	for idx, param := range f.parameters {
+		if f.defaults[idx] != nil {
+			params = append(params, fmt.Sprintf("%v = %v", param.toString(), f.defaults[idx].toString()))
+		} else {
+			params = append(params, param.toString())
+		}
+	}
+	if f.rest != nil {
+		params = append(params, "..."+f.rest.toString())
 	}
 
 	return fmt.Sprintf("fn(%v)", strings.Join(params, ", "))
@@ -148,18 +187,42 @@ func (f *Function) inspect() string {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// Hashes remember insertion order, and everything that walks a hash visits pairs in that order.
 type Hash struct {
-	pairs map[HashKey]HashPair
+	pairs   map[HashKey]HashPair
+	order   []HashKey
+	factory Object
+	frozen  bool
+}
+
+func newHash() *Hash {
+	return &Hash{pairs: make(map[HashKey]HashPair), order: make([]HashKey, 0)}
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
 
-func (h *Hash) inspect() string {
+func (h *Hash) set(key HashKey, pair HashPair) {
+	if _, ok := h.pairs[key]; !ok {
+		h.order = append(h.order, key)
+	}
+	h.pairs[key] = pair
+}
+
+func (h *Hash) orderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.order))
+	for _, key := range h.order {
+		pairs = append(pairs, h.pairs[key])
+	}
+
+	return pairs
+}
+
+func (h *Hash) Inspect() string {
 	var buffer bytes.Buffer
 	pairs := make([]string, 0)
 
-	for _, pair := range h.pairs {
-		pairs = append(pairs, fmt.Sprintf("%v: %v", pair.key.inspect(), pair.value.inspect()))
+	for _, pair := range h.orderedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%v: %v", pair.key.Inspect(), pair.value.Inspect()))
 	}
 
 	buffer.WriteString("{")
@@ -191,7 +254,7 @@ type Integer struct {
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
-func (i *Integer) inspect() string { return fmt.Sprintf("%v", i.value) }
+func (i *Integer) Inspect() string { return fmt.Sprintf("%v", i.value) }
 
 func (i *Integer) HashKey() HashKey {
 	return HashKey{keyType: i.Type(), value: uint64(i.value)}
@@ -199,11 +262,22 @@ func (i *Integer) HashKey() HashKey {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+type Module struct {
+	name     string
+	bindings map[string]Object
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+func (m *Module) Inspect() string { return fmt.Sprintf("module(%v)", m.name) }
+
+// --------------------------------------------------------------------------------------------------------------------
+
 type Null struct{}
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 
-func (n *Null) inspect() string { return "null" }
+func (n *Null) Inspect() string { return "null" }
 
 // --------------------------------------------------------------------------------------------------------------------
 
@@ -213,7 +287,7 @@ type ReturnValue struct {
 
 func (r *ReturnValue) Type() ObjectType { return RETURN_OBJ }
 
-func (r *ReturnValue) inspect() string { return r.value.inspect() }
+func (r *ReturnValue) Inspect() string { return r.value.Inspect() }
 
 // --------------------------------------------------------------------------------------------------------------------
 
@@ -223,7 +297,7 @@ type StringValue struct {
 
 func (s *StringValue) Type() ObjectType { return STRING_OBJ }
 
-func (s *StringValue) inspect() string { return s.value }
+func (s *StringValue) Inspect() string { return s.value }
 
 func (s *StringValue) HashKey() HashKey {
 	hash := fnv.New64a()
@@ -237,21 +311,26 @@ func (s *StringValue) HashKey() HashKey {
 // --------------------------------------------------------------------------------------------------------------------
 
 type Environment struct {
-	store map[string]Object
-	outer *Environment
+	store    map[string]Object
+	consts   map[string]bool
+	builtins map[string]*Builtin
+	outer    *Environment
+	interp   *Interpreter
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func newEnvironment() *Environment {
+func newEnvironment(interp *Interpreter) *Environment {
 	store := make(map[string]Object)
-	return &Environment{store: store, outer: nil}
+	consts := make(map[string]bool)
+	builtins := make(map[string]*Builtin)
+	return &Environment{store: store, consts: consts, builtins: builtins, outer: nil, interp: interp}
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
 func newEnclosedEnvironment(outer *Environment) *Environment {
-	env := newEnvironment()
+	env := newEnvironment(outer.interp)
 	env.outer = outer
 
 	return env
@@ -259,10 +338,10 @@ func newEnclosedEnvironment(outer *Environment) *Environment {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (e *Environment) get(name string) (Object, bool) {
+func (e *Environment) Get(name string) (Object, bool) {
 	obj, ok := e.store[name]
 	if !ok && e.outer != nil {
-		obj, ok = e.outer.get(name)
+		obj, ok = e.outer.Get(name)
 	}
 
 	return obj, ok
@@ -270,10 +349,97 @@ func (e *Environment) get(name string) (Object, bool) {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (e *Environment) set(name string, val Object) Object {
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+
+	return val
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Only removes the binding from this scope, outer scopes are left alone.
+func (e *Environment) delete(name string) bool {
+	_, ok := e.store[name]
+	delete(e.store, name)
+	delete(e.consts, name)
+
+	return ok
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Names visible from this scope, in sorted order. A name shadowing an outer one is only listed once.
+func (e *Environment) names() []string {
+	seen := make(map[string]bool)
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			seen[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *Environment) setConst(name string, val Object) Object {
 	e.store[name] = val
+	e.consts[name] = true
 
 	return val
 }
 
 // --------------------------------------------------------------------------------------------------------------------
+
+// Reports whether the binding name resolves to is a constant.
+func (e *Environment) isConst(name string) bool {
+	if _, ok := e.store[name]; ok {
+		return e.consts[name]
+	}
+	if e.outer != nil {
+		return e.outer.isConst(name)
+	}
+
+	return false
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Updates an existing binding in whichever scope defines it.
+func (e *Environment) assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.assign(name, val)
+	}
+
+	return nil, false
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+// Makes fn callable as name from this scope and any scope nested in it. It shadows a global builtin of the same name.
+func (e *Environment) RegisterBuiltin(name string, fn BuiltinFunc) {
+	e.builtins[name] = &Builtin{fn: fn}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (e *Environment) getBuiltin(name string) (*Builtin, bool) {
+	builtin, ok := e.builtins[name]
+	if !ok && e.outer != nil {
+		builtin, ok = e.outer.getBuiltin(name)
+	}
+
+	return builtin, ok
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -1,8 +1,10 @@
-package main
+package monkey
 
 import (
 	"fmt"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -30,6 +32,7 @@ func newParser(lexer *Lexer) *Parser {
 
 const (
 	LOWEST = iota
+	COALESCING
 	EQUALS
 	LESSGREATER
 	SUM
@@ -45,6 +48,7 @@ const (
 
 type prefixParsingFn func() Expression
 type infixParsingFn func(Expression) Expression
+type postfixParsingFn func(Expression) Expression
 
 // --------------------------------------------------------------------------------------------------------------------
 
@@ -73,6 +77,8 @@ func (p *Parser) parseStatement() Statement {
 	switch p.cur.tokenType {
 	case LET:
 		return p.parseLetStatement()
+	case CONST:
+		return p.parseConstStatement()
 	case RETURN:
 		return p.parseReturnStatement()
 	default:
@@ -136,6 +142,28 @@ func (p *Parser) parseLetStatement() *LetStatement {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func (p *Parser) parseConstStatement() *ConstStatement {
+	stmt := &ConstStatement{token: p.cur}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+
+	stmt.name = &Identifier{token: p.cur, value: p.cur.literal}
+	if !p.expectPeek(ASSIGN) {
+		return nil
+	}
+	p.nextToken()
+
+	stmt.value = p.parseExpression(LOWEST)
+	if p.peek.tokenType == SEMICOLON {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (p *Parser) parseReturnStatement() *ReturnStatement {
 	stmt := &ReturnStatement{token: p.cur}
 	p.nextToken()
@@ -160,7 +188,14 @@ func (p *Parser) parseExpression(prec int) Expression {
 	}
 	leftExpr := prefix()
 
-	for p.peek.tokenType != SEMICOLON && prec < p.getPrec(p.peek.tokenType) {
+	for p.peek.tokenType != SEMICOLON && prec < tokenPrecedence(p.peek.tokenType) {
+		// Postfix operators take only the expression to their left, so they are tried before infix ones.
+		if postfix := p.getPostfixFn(p.peek.tokenType); postfix != nil {
+			p.nextToken()
+			leftExpr = postfix(leftExpr)
+			continue
+		}
+
 		infix := p.getInfixFn(p.peek.tokenType)
 		if infix == nil {
 			return leftExpr
@@ -198,67 +233,104 @@ func (p *Parser) parseCallExpression(function Expression) Expression {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) parseCallArgs() []Expression {
-	args := make([]Expression, 0)
+func (p *Parser) parseExpressionList(end TokenType) []Expression {
+	list := make([]Expression, 0)
 
-	if p.peek.tokenType == RPAREN {
+	if p.peek.tokenType == end {
 		p.nextToken()
-		return args
+		return list
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peek.tokenType == COMMA {
 		p.nextToken()
+		if p.peek.tokenType == end {
+			break
+		}
 		p.nextToken()
-
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
 
-	return args
+	return list
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) parseExpressionList(end TokenType) []Expression {
-	list := make([]Expression, 0)
+func (p *Parser) parseFloatLiteral() Expression {
+	literal, ok := stripDigitSeparators(p.cur.literal)
+	if !ok {
+		p.digitSeparatorError()
+		return nil
+	}
 
-	if p.peek.tokenType == end {
-		p.nextToken()
-		return list
+	value, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		p.numberParsingError()
+		return nil
 	}
 
-	p.nextToken()
-	list = append(list, p.parseExpression(LOWEST))
+	return &FloatLiteral{token: p.cur, value: value}
+}
 
-	for p.peek.tokenType == COMMA {
-		p.nextToken()
-		p.nextToken()
-		list = append(list, p.parseExpression(LOWEST))
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) parseDoWhileExpression() Expression {
+	expr := &DoWhileExpression{token: p.cur}
+	if !p.expectPeek(LBRACE) {
+		return nil
 	}
 
-	if !p.expectPeek(end) {
+	expr.body = p.parseBlockStatement()
+	if !p.expectPeek(WHILE) {
+		return nil
+	}
+	if !p.expectPeek(LPAREN) {
 		return nil
 	}
 
-	return list
+	p.nextToken()
+	expr.condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+
+	return expr
 }
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) parseFloatLiteral() Expression {
-	value, err := strconv.ParseFloat(p.cur.literal, 64)
-	if err != nil {
-		p.numberParsingError()
+func (p *Parser) parseForInExpression() Expression {
+	expr := &ForInExpression{token: p.cur}
+	if !p.expectPeek(LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(IDENT) {
 		return nil
 	}
 
-	return &FloatLiteral{token: p.cur, value: value}
+	expr.variable = &Identifier{token: p.cur, value: p.cur.literal}
+	if !p.expectPeek(IN) {
+		return nil
+	}
+
+	p.nextToken()
+	expr.iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(LBRACE) {
+		return nil
+	}
+
+	expr.body = p.parseBlockStatement()
+
+	return expr
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -269,7 +341,9 @@ func (p *Parser) parseFunctionLiteral() Expression {
 		return nil
 	}
 
-	funcLit.parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(funcLit) {
+		return nil
+	}
 	if !p.expectPeek(LBRACE) {
 		return nil
 	}
@@ -281,30 +355,64 @@ func (p *Parser) parseFunctionLiteral() Expression {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) parseFunctionParameters() []*Identifier {
-	idents := make([]*Identifier, 0)
+func (p *Parser) parseFunctionParameters(funcLit *FunctionLiteral) bool {
+	funcLit.parameters = make([]*Identifier, 0)
+	funcLit.defaults = make([]Expression, 0)
 
 	if p.peek.tokenType == RPAREN {
 		p.nextToken()
-		return idents
+		return true
 	}
 
-	p.nextToken()
-	ident := &Identifier{token: p.cur, value: p.cur.literal}
-	idents = append(idents, ident)
+	for {
+		if p.peek.tokenType == ELLIPSIS {
+			p.nextToken()
+			if !p.expectPeek(IDENT) {
+				return false
+			}
+			funcLit.rest = &Identifier{token: p.cur, value: p.cur.literal}
+			if p.peek.tokenType == COMMA {
+				p.nextToken()
+			}
+			break
+		}
+		if !p.expectPeek(IDENT) {
+			return false
+		}
 
-	for p.peek.tokenType == COMMA {
-		p.nextToken()
+		count := len(funcLit.defaults)
+		ident, value := p.parseFunctionParameter(count > 0 && funcLit.defaults[count-1] != nil)
+		funcLit.parameters = append(funcLit.parameters, ident)
+		funcLit.defaults = append(funcLit.defaults, value)
+
+		if p.peek.tokenType != COMMA {
+			break
+		}
 		p.nextToken()
-		ident := &Identifier{token: p.cur, value: p.cur.literal}
-		idents = append(idents, ident)
+		if p.peek.tokenType == RPAREN {
+			break
+		}
 	}
 
-	if !p.expectPeek(RPAREN) {
-		return nil
+	return p.expectPeek(RPAREN)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) parseFunctionParameter(needsDefault bool) (*Identifier, Expression) {
+	ident := &Identifier{token: p.cur, value: p.cur.literal}
+
+	if p.peek.tokenType != ASSIGN {
+		if needsDefault {
+			p.missingDefaultError(ident)
+		}
+		return ident, nil
 	}
 
-	return idents
+	p.nextToken()
+	p.nextToken()
+
+	return ident, p.parseExpression(LOWEST)
 }
 
 // --------------------------------------------------------------------------------------------------------------------
@@ -336,6 +444,7 @@ func (p *Parser) parseHashLiteral() Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.pairs[key] = value
+		hash.keys = append(hash.keys, key)
 
 		if p.peek.tokenType != RBRACE && !p.expectPeek(COMMA) {
 			return nil
@@ -376,6 +485,10 @@ func (p *Parser) parseIfExpression() Expression {
 
 	if p.peek.tokenType == ELSE {
 		p.nextToken()
+		if p.peek.tokenType == IF {
+			expr.alternative = p.parseElseIf()
+			return expr
+		}
 		if !p.expectPeek(LBRACE) {
 			return nil
 		}
@@ -387,6 +500,25 @@ func (p *Parser) parseIfExpression() Expression {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+// An else-if is stored as an alternative block holding just the nested if, tagged with the IF token.
+func (p *Parser) parseElseIf() *BlockStatement {
+	p.nextToken()
+	block := &BlockStatement{token: p.cur}
+	stmt := &ExpressionStatement{token: p.cur, expression: p.parseIfExpression()}
+	block.statements = []Statement{stmt}
+
+	return block
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) parseIllegal() Expression {
+	p.illegalTokenError()
+	return nil
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (p *Parser) parseIndexExpression(left Expression) Expression {
 	expr := &IndexExpression{token: p.cur, left: left}
 	p.nextToken()
@@ -403,7 +535,7 @@ func (p *Parser) parseIndexExpression(left Expression) Expression {
 
 func (p *Parser) parseInfixExpression(left Expression) Expression {
 	expr := &InfixExpression{token: p.cur, operator: p.cur.literal, left: left}
-	prec := p.getPrec(p.cur.tokenType)
+	prec := tokenPrecedence(p.cur.tokenType)
 	p.nextToken()
 	expr.right = p.parseExpression(prec)
 
@@ -413,7 +545,13 @@ func (p *Parser) parseInfixExpression(left Expression) Expression {
 // --------------------------------------------------------------------------------------------------------------------
 
 func (p *Parser) parseIntegerLiteral() Expression {
-	value, err := strconv.ParseInt(p.cur.literal, 0, 64)
+	literal, ok := stripDigitSeparators(p.cur.literal)
+	if !ok {
+		p.digitSeparatorError()
+		return nil
+	}
+
+	value, err := strconv.ParseInt(literal, 0, 64)
 	if err != nil {
 		p.numberParsingError()
 		return nil
@@ -424,6 +562,43 @@ func (p *Parser) parseIntegerLiteral() Expression {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func (p *Parser) parseCharLiteral() Expression {
+	value, ok := decodeCharLiteral(p.cur.literal)
+	if !ok {
+		p.charLiteralError()
+		return nil
+	}
+
+	return &CharLiteral{token: p.cur, value: value}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) parseMemberExpression(object Expression) Expression {
+	expr := &MemberExpression{token: p.cur, object: object}
+	if !p.expectPeek(IDENT) {
+		return nil
+	}
+
+	expr.member = &Identifier{token: p.cur, value: p.cur.literal}
+
+	return expr
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) parsePostfixExpression(left Expression) Expression {
+	ident, ok := left.(*Identifier)
+	if !ok {
+		p.invalidPostfixOperandError(left)
+		return nil
+	}
+
+	return &PostfixExpression{token: p.cur, operator: p.cur.literal, left: ident}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (p *Parser) parsePrefixExpression() Expression {
 	expr := &PrefixExpression{token: p.cur, operator: p.cur.literal}
 	p.nextToken()
@@ -442,8 +617,10 @@ func (p *Parser) parseStringLiteral() Expression {
 // Helpers
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) getPrec(tokenType TokenType) int {
+func tokenPrecedence(tokenType TokenType) int {
 	switch tokenType {
+	case COALESCE:
+		return COALESCING
 	case EQ, NOTEQ:
 		return EQUALS
 	case LT, LTEQ, GT, GTEQ:
@@ -454,7 +631,7 @@ func (p *Parser) getPrec(tokenType TokenType) int {
 		return PRODUCT
 	case LPAREN:
 		return CALL
-	case LBRACKET:
+	case LBRACKET, DOT, OPTIONAL_LBRACKET, OPTIONAL_DOT, INCREMENT, DECREMENT:
 		return INDEX
 	default:
 		return LOWEST
@@ -463,14 +640,71 @@ func (p *Parser) getPrec(tokenType TokenType) int {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func decodeCharLiteral(literal string) (rune, bool) {
+	if strings.HasPrefix(literal, "\\") {
+		if len(literal) != 2 {
+			return 0, false
+		}
+		switch literal[1] {
+		case 'n':
+			return '\n', true
+		case 't':
+			return '\t', true
+		case 'r':
+			return '\r', true
+		case '0':
+			return 0, true
+		case '\\', '\'', '"':
+			return rune(literal[1]), true
+		default:
+			return 0, false
+		}
+	}
+
+	value, width := utf8.DecodeRuneInString(literal)
+	if width == 0 || width != len(literal) || value == utf8.RuneError {
+		return 0, false
+	}
+
+	return value, true
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func stripDigitSeparators(literal string) (string, bool) {
+	if strings.HasPrefix(literal, "_") || strings.HasSuffix(literal, "_") {
+		return literal, false
+	}
+	if strings.Contains(literal, "__") || strings.Contains(literal, "_.") || strings.Contains(literal, "._") {
+		return literal, false
+	}
+
+	return strings.ReplaceAll(literal, "_", ""), true
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (p *Parser) getInfixFn(tokenType TokenType) infixParsingFn {
 	switch tokenType {
-	case PLUS, MINUS, SLASH, ASTERIX, MODULO, EQ, NOTEQ, LT, LTEQ, GT, GTEQ:
+	case PLUS, MINUS, SLASH, ASTERIX, MODULO, EQ, NOTEQ, LT, LTEQ, GT, GTEQ, COALESCE:
 		return p.parseInfixExpression
 	case LPAREN:
 		return p.parseCallExpression
-	case LBRACKET:
+	case LBRACKET, OPTIONAL_LBRACKET:
 		return p.parseIndexExpression
+	case DOT, OPTIONAL_DOT:
+		return p.parseMemberExpression
+	default:
+		return nil
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) getPostfixFn(tokenType TokenType) postfixParsingFn {
+	switch tokenType {
+	case INCREMENT, DECREMENT:
+		return p.parsePostfixExpression
 	default:
 		return nil
 	}
@@ -486,21 +720,29 @@ func (p *Parser) getPrefixFn(tokenType TokenType) prefixParsingFn {
 		return p.parseBooleanLiteral
 	case FLOAT:
 		return p.parseFloatLiteral
+	case DO:
+		return p.parseDoWhileExpression
+	case FOR:
+		return p.parseForInExpression
 	case FUNCTION:
 		return p.parseFunctionLiteral
 	case IDENT:
 		return p.parseIdentifier
 	case IF:
 		return p.parseIfExpression
+	case ILLEGAL:
+		return p.parseIllegal
 	case INT:
 		return p.parseIntegerLiteral
+	case CHAR:
+		return p.parseCharLiteral
 	case LBRACE:
 		return p.parseHashLiteral
 	case LBRACKET:
 		return p.parseArrayLiteral
 	case LPAREN:
 		return p.parseGroupedExpr
-	case STRING:
+	case STRING, RAW_STRING:
 		return p.parseStringLiteral
 	default:
 		return nil
@@ -544,10 +786,48 @@ func (p *Parser) noPrefixParsingFnError(tokenType TokenType) {
 
 // --------------------------------------------------------------------------------------------------------------------
 
-func (p *Parser) numberParsingError() {
+func (p *Parser) digitSeparatorError() {
+	errMsg := fmt.Sprintf(
+		"Error: misplaced underscore in number -> { %v }. Underscores must sit between digits. On %v.",
+		p.cur.literal,
+		tokenSpan(p.cur),
+	)
+
+	p.errors = append(p.errors, errMsg)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) illegalTokenError() {
+	errMsg := fmt.Sprintf(
+		"Error: illegal token -> { %v }. On %v.",
+		p.cur.literal,
+		tokenSpan(p.cur),
+	)
+
+	p.errors = append(p.errors, errMsg)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) missingDefaultError(ident *Identifier) {
+	errMsg := fmt.Sprintf(
+		"Error: parameter -> { %v } must have a default value as it follows a defaulted parameter. On line %v, column %v.",
+		ident.value,
+		ident.token.line,
+		ident.token.column,
+	)
+
+	p.errors = append(p.errors, errMsg)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) invalidPostfixOperandError(operand Expression) {
 	errMsg := fmt.Sprintf(
-		"Error: could not parse -> { %v } into a number. On line %v , column %v.",
+		"Error: operator -> { %v } can only be applied to a variable, got { %v }. On line %v, column %v.",
 		p.cur.literal,
+		operand.toString(),
 		p.cur.line,
 		p.cur.column,
 	)
@@ -557,6 +837,30 @@ func (p *Parser) numberParsingError() {
 
 // --------------------------------------------------------------------------------------------------------------------
 
+func (p *Parser) charLiteralError() {
+	errMsg := fmt.Sprintf(
+		"Error: invalid character literal -> { '%v' }. Character literals hold exactly one character. On %v.",
+		p.cur.literal,
+		tokenSpan(p.cur),
+	)
+
+	p.errors = append(p.errors, errMsg)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func (p *Parser) numberParsingError() {
+	errMsg := fmt.Sprintf(
+		"Error: could not parse -> { %v } into a number. On %v.",
+		p.cur.literal,
+		tokenSpan(p.cur),
+	)
+
+	p.errors = append(p.errors, errMsg)
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
 func (p *Parser) peekError(expected TokenType) {
 	errMsg := fmt.Sprintf(
 		"Error: got wrong expected type -> { %v }, wanted -> { %v }. On line: %v, column %v. ",
@@ -570,3 +874,16 @@ func (p *Parser) peekError(expected TokenType) {
 }
 
 // --------------------------------------------------------------------------------------------------------------------
+
+func tokenSpan(tok Token) string {
+	switch {
+	case tok.endLine != tok.line:
+		return fmt.Sprintf("line %v, column %v to line %v, column %v", tok.line, tok.column, tok.endLine, tok.endColumn)
+	case tok.endColumn > tok.column:
+		return fmt.Sprintf("line %v, columns %v-%v", tok.line, tok.column, tok.endColumn)
+	default:
+		return fmt.Sprintf("line %v, column %v", tok.line, tok.column)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -0,0 +1,243 @@
+package monkey
+
+import (
+	"strings"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Helpers
+// --------------------------------------------------------------------------------------------------------------------
+
+type parseCase struct {
+	input    string
+	expected string
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func parseSource(input string) (*Program, []string) {
+	parser := newParser(newLexer(input))
+	program := parser.parseProgram()
+
+	return program, parser.errors
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func runParseCases(t *testing.T, cases []parseCase) {
+	t.Helper()
+
+	for _, tc := range cases {
+		program, errors := parseSource(tc.input)
+		if len(errors) > 0 {
+			t.Errorf("%q: unexpected parse errors: %v", tc.input, errors)
+			continue
+		}
+		if got := program.toString(); got != tc.expected {
+			t.Errorf("%q: got %q, want %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func expectParseErrors(t *testing.T, inputs []string) {
+	t.Helper()
+
+	for _, input := range inputs {
+		if _, errors := parseSource(input); len(errors) == 0 {
+			t.Errorf("%q: expected a parse error", input)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Call expressions
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCallArguments(t *testing.T) {
+	cases := []struct {
+		input     string
+		arguments int
+	}{
+		{`f()`, 0},
+		{`f(1)`, 1},
+		{`f(1, 2 + 3)`, 2},
+		{`f(1, 2, 3,)`, 3},
+		{`f(g(1), h(2, 3))`, 2},
+	}
+
+	for _, tc := range cases {
+		program, errors := parseSource(tc.input)
+		if len(errors) > 0 {
+			t.Errorf("%q: unexpected parse errors: %v", tc.input, errors)
+			continue
+		}
+		stmt, ok := program.statements[0].(*ExpressionStatement)
+		if !ok {
+			t.Errorf("%q: got %T, want *ExpressionStatement", tc.input, program.statements[0])
+			continue
+		}
+		call, ok := stmt.expression.(*CallExpression)
+		if !ok {
+			t.Errorf("%q: got %T, want *CallExpression", tc.input, stmt.expression)
+			continue
+		}
+		if len(call.arguments) != tc.arguments {
+			t.Errorf("%q: got %v arguments, want %v", tc.input, len(call.arguments), tc.arguments)
+		}
+	}
+
+	runParseCases(t, []parseCase{
+		{`f(1, 2 + 3)`, "f(1, (2 + 3)) "},
+		{`f(g(1), h(2, 3))`, "f(g(1), h(2, 3)) "},
+		{`f(1)(2)`, "f(1)(2) "},
+	})
+
+	expectParseErrors(t, []string{`f(1,,2)`, `f(,)`, `f(1`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Trailing commas
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestTrailingCommas(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{`[1, 2, 3,]`, "[1, 2, 3] "},
+		{`f(a, b,)`, "f(a, b) "},
+		{`fn(a, b,) { a }`, "fn(a, b)a "},
+		{`fn(a, b = 2, ...c) { a }`, "fn(a, b = 2, ...c)a "},
+	})
+
+	expectParseErrors(t, []string{`[,]`, `[1,,]`, `fn(,) { 1 }`, `fn(1, 2) { 1 }`, `fn(a, "b") { a }`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Number literals
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestPrefixedIntegerLiterals(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[0xFF, 0XfF, 0b1010, 0o17, 0x0]`, "[255, 255, 10, 15, 0]"},
+		{`0x7FFFFFFFFFFFFFFF`, "9223372036854775807"},
+	})
+
+	expectParseErrors(t, []string{`0xG1`, `0b102`, `0o8`, `0x`, `0x8000000000000000`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestUnderscoresInNumbers(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[1_000_000, 0xFF_FF, 0b1_0]`, "[1000000, 65535, 2]"},
+		{`3.141_592`, "3.141592"},
+	})
+
+	expectParseErrors(t, []string{`1__0`, `5_`, `1_.5`, `1._5`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestScientificNotation(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[1e10, 2.5e-3, 1E+2, 5e0]`, "[1e+10, 0.0025, 100, 5]"},
+		{`1_0e1_0`, "1e+11"},
+	})
+
+	if result := testEval(t, `1e2`); result.Type() != FLOAT_OBJ {
+		t.Errorf("1e2: got %v, want FLOAT", result.Type())
+	}
+
+	expectParseErrors(t, []string{`1e`, `1e+`, `2.5e-`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestMalformedDecimalPoints(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`[1.5, 0.25, 10.0]`, "[1.5, 0.25, 10]"},
+		// A dot after a name is still a method call.
+		{`let a = [1, 2]; a.len()`, "2"},
+	})
+
+	for _, input := range []string{`1.2.3`, `.5`, `5.`} {
+		_, errors := parseSource(input)
+		if len(errors) != 1 || !strings.Contains(errors[0], "illegal token -> { "+input+" }") {
+			t.Errorf("%q: got errors %v, want a single illegal token error", input, errors)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// If expressions
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestElseIfChains(t *testing.T) {
+	runParseCases(t, []parseCase{
+		{`if (a) { 1 } else if (b) { 2 } else { 3 }`, "if a 1 else if b 2 else 3 "},
+		{`if (a) { 1 } else if (b) { 2 }`, "if a 1 else if b 2 "},
+		{`if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }`, "if a 1 else if b 2 else if c 3 else 4 "},
+		{"if (a) { 1 }\nelse if (b) { 2 }\nelse { 3 }", "if a 1 else if b 2 else 3 "},
+	})
+
+	expectParseErrors(t, []string{
+		`if (a) { 1 } else if { 2 }`,
+		`if (a) { 1 } else if (b)`,
+		`if (a) { 1 } else if (b) { 2 } else`,
+	})
+
+	runEvalCases(t, []evalCase{
+		{
+			`let f = fn(x) { if (x < 0) { "neg" } else if (x == 0) { "zero" } else if (x < 10) { "small" } else { "big" } };
+			[f(-1), f(0), f(5), f(50)]`,
+			"[neg, zero, small, big]",
+		},
+		// Without a final else, a chain where nothing matches is null.
+		{`if (false) { 1 } else if (false) { 2 }`, "null"},
+		{`let x = 1; if (x > 5) { 1 } else if (x > 0) { 2 }`, "2"},
+		// Only the first matching branch runs.
+		{`let n = 0; if (true) { n++ } else if (true) { n++; n++ }; n`, "1"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Character literals
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestCharLiterals(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`['a', 'Z', '0', ' ']`, "[97, 90, 48, 32]"},
+		{`['\n', '\t', '\\', '\'']`, "[10, 9, 92, 39]"},
+		// Multibyte characters are a single code point.
+		{`['é', '名', '😀']`, "[233, 21517, 128512]"},
+		{`chr('é')`, "é"},
+		{`'a' + 1`, "98"},
+	})
+
+	expectParseErrors(t, []string{`''`, `'ab'`, `'a`, `'\q'`, `'éé'`})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+// Raw strings
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestRawStrings(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{"`say \"hi\"`", `say "hi"`},
+		// Backslashes and line breaks are kept as written.
+		{"`a\\nb`", `a\nb`},
+		{"let s = `a\nb`; [s, len(s)]", "[a\nb, 3]"},
+		// Lines inside a raw string still count towards later positions.
+		{"let s = `a\nb`\nmissing", "Error [3:1]: identifier not found {missing}."},
+	})
+
+	for _, input := range []string{"`abc", "`unterminated\nover lines", "let s = `"} {
+		_, errors := parseSource(input)
+		if len(errors) == 0 || !strings.Contains(errors[0], "illegal token") {
+			t.Errorf("%q: got errors %v, want an illegal token error", input, errors)
+		}
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
@@ -1,70 +1,85 @@
-package main
+package monkey
+
+import "strings"
+
+const (
+	LAST_ERROR_SLOT = "__last_error__"
+	MAX_EVAL_DEPTH  = 100
+)
 
 func loadNativeBuiltins(env *Environment) {
-	loadMap(env)
-	loadRecude(env)
-	loadFilter(env)
+	loadLastError(env)
+	loadVars(env)
+	loadEval(env)
 }
 
-func loadMap(env *Environment) {
-	input := `
-	let map = 
-		fn(arr, function) {
-			let iter = fn(arr, acc) { 
-				if (len(arr) == 0) { 
-					acc 
-				} else { 
-				 	iter(rest(arr), push(acc, function(first(arr))))
-				}
-			} 
-			iter(arr, [])
-		}
-`
-	lexer := newLexer(input)
-	parser := newParser(lexer)
-	program := parser.parseProgram()
-	eval(program, env)
-}
+func loadLastError(env *Environment) {
+	env.Set(LAST_ERROR_SLOT, &NullObject)
 
-func loadRecude(env *Environment) {
 	input := `
-	let reduce = fn(arr, initial, function) {
-		let iter = fn(arr, result) {
-			if (len(arr) == 0) {
-			result
-		} else {
-			iter(rest(arr), function(result, first(arr)));
-		}
-	}
-		iter(arr, initial)
+	let last_error = fn() {
+		__last_error__
 	}
 `
-	lexer := newLexer(input)
-	parser := newParser(lexer)
-	program := parser.parseProgram()
-	eval(program, env)
+	evalSource(input, env)
 }
 
-func loadFilter(env *Environment) {
-	input := `
-	let filter = fn(arr, function) {
-		let iter = fn(arr, acc) {
-			if (len(arr) == 0) {
-				acc
-			} else {
-			 	let working = first(arr)
-				if (function(working)) {
-					iter(rest(arr), push(acc, working))
-				} else {
-					iter(rest(arr), acc) 
-				}			
+// Lists the user bindings visible where vars is called, locals included. Anything bound before vars is loaded, like
+// last_error, counts as a builtin.
+func loadVars(env *Environment) {
+	preloaded := make(map[string]bool)
+	for _, name := range env.names() {
+		preloaded[name] = true
+	}
+
+	env.RegisterBuiltin("vars", func(caller *Environment, args ...Object) Object {
+		if len(args) != 0 {
+			return newError("vars: wrong number of arguments. Got %v, want 0", len(args))
+		}
+
+		names := make([]Object, 0)
+		for _, name := range caller.names() {
+			if !preloaded[name] {
+				names = append(names, &StringValue{value: name})
 			}
 		}
-		iter(arr, [])
-	}
-`
-	lexer := newLexer(input)
-	parser := newParser(lexer)
+
+		return &Array{elements: names}
+	})
+}
+
+// Source is evaluated in the scope eval is called from, so it sees that scope's locals and its lets bind there.
+func loadEval(env *Environment) {
+	env.RegisterBuiltin("eval", func(caller *Environment, args ...Object) Object {
+		if len(args) != 1 {
+			return newError("eval: wrong number of arguments. Got %v, want 1", len(args))
+		}
+		if args[0].Type() != STRING_OBJ {
+			return newError("eval: argument to eval must be a String, got %v.", args[0].Type())
+		}
+		interp := caller.interp
+		if interp.evalDepth >= MAX_EVAL_DEPTH {
+			return newError("eval: maximum eval nesting depth exceeded (%v).", MAX_EVAL_DEPTH)
+		}
+
+		interp.evalDepth += 1
+		defer func() { interp.evalDepth -= 1 }()
+
+		return evalSource(args[0].(*StringValue).value, caller)
+	})
+}
+
+func evalSource(src string, env *Environment) Object {
+	parser := newParser(newLexer(src))
 	program := parser.parseProgram()
-	eval(program, env)
+	if len(parser.errors) != 0 {
+		return newError("eval: could not parse source: %v", strings.Join(parser.errors, " "))
+	}
+
+	// An empty program has no value of its own.
+	if result := eval(program, env); result != nil {
+		return result
+	}
+
+	return &NullObject
 }
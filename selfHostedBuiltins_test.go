@@ -0,0 +1,101 @@
+package monkey
+
+import (
+	"strings"
+	"testing"
+)
+
+// --------------------------------------------------------------------------------------------------------------------
+// Environment aware builtins
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestVars(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`vars()`, "[]"},
+		{`let b = 1; let a = 2; vars()`, "[a, b]"},
+		{`let g = fn(y) { vars() }; g(1)`, "[g, y]"},
+		{`let g = fn(y) { let z = 0; vars() }; g(1)`, "[g, y, z]"},
+		// A local shadowing an outer name is listed once.
+		{`let y = 0; let g = fn(y) { vars() }; g(1)`, "[g, y]"},
+		{`let g = fn() { vars() }; g(); let late = 1; vars()`, "[g, late]"},
+		{`vars(1)`, "Error [1:5]: vars: wrong number of arguments. Got 1, want 0"},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestEval(t *testing.T) {
+	runEvalCases(t, []evalCase{
+		{`eval("1 + 2 * 3")`, "7"},
+		{`eval("")`, "null"},
+		{`eval("let y = 10;"); y * 2`, "20"},
+		{`let f = fn(x) { eval("x") }; f(1)`, "1"},
+		{`let f = fn(x) { eval("let z = x + 1"); z }; f(1)`, "2"},
+		{`let f = fn() { eval("let local = 1") }; f(); vars()`, "[f]"},
+		{`eval("1 / 0")`, "Error [1:3]: division by zero."},
+		{`let s = "eval(s)"; eval(s)`, "Error [1:5]: eval: maximum eval nesting depth exceeded (100)."},
+		{`eval(1)`, "Error [1:5]: eval: argument to eval must be a String, got INTEGER."},
+	})
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestEvalParseError(t *testing.T) {
+	result := testEval(t, `eval("let = 1;")`)
+
+	err, ok := result.(*Error)
+	if !ok {
+		t.Fatalf("got %v, want an Error", result.Inspect())
+	}
+	if !strings.HasPrefix(err.message, "eval: could not parse source: ") {
+		t.Errorf("got message %q", err.message)
+	}
+	if err.line != 1 || err.column != 5 {
+		t.Errorf("got position %v:%v, want 1:5", err.line, err.column)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
+
+func TestLastError(t *testing.T) {
+	interp := New()
+
+	steps := []struct {
+		input    string
+		failed   bool
+		expected string
+	}{
+		{`last_error()`, false, "null"},
+		{`let x = 1 / 0;`, true, "Error [1:11]: division by zero."},
+		{`last_error()`, false, "{message: division by zero., line: 1, column: 11}"},
+		// Reading the record doesn't raise the old error again.
+		{`let e = last_error(); 5`, false, "5"},
+		{`let e = last_error(); [e["message"], e["line"]]`, false, "[division by zero., 1]"},
+		{`missing`, true, "Error [1:1]: identifier not found {missing}."},
+		{`last_error()["message"]`, false, "identifier not found {missing}."},
+	}
+
+	for _, step := range steps {
+		result, err := interp.Run(step.input)
+		if (err != nil) != step.failed {
+			t.Fatalf("%q: got error %v, want failure %v", step.input, err, step.failed)
+		}
+		if got := result.Inspect(); got != step.expected {
+			t.Errorf("%q: got %v, want %v", step.input, got, step.expected)
+		}
+	}
+
+	// Parse errors are recorded without a position.
+	if _, err := interp.Run(`let = 1;`); err == nil {
+		t.Fatalf("expected a parse error")
+	}
+	result, err := interp.Run(`let e = last_error(); [e["line"], e["column"], len(e["message"]) > 0]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := result.Inspect(), "[0, 0, true]"; got != want {
+		t.Errorf("after a parse error: got %v, want %v", got, want)
+	}
+}
+
+// --------------------------------------------------------------------------------------------------------------------
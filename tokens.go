@@ -1,21 +1,24 @@
-package main
+package monkey
 
 type TokenType string
 
 type Token struct {
-	tokenType    TokenType
-	literal      string
-	line, column int
+	tokenType          TokenType
+	literal            string
+	line, column       int
+	endLine, endColumn int
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
 
-	IDENT  = "IDENT"
-	INT    = "INT"
-	FLOAT  = "FLOAT"
-	STRING = "STRING"
+	IDENT      = "IDENT"
+	INT        = "INT"
+	FLOAT      = "FLOAT"
+	STRING     = "STRING"
+	RAW_STRING = "RAW_STRING"
+	CHAR       = "CHAR"
 
 	ASSIGN  = "="
 	PLUS    = "+"
@@ -24,6 +27,10 @@ const (
 	SLASH   = "/"
 	MODULO  = "%"
 
+	INCREMENT = "++"
+	DECREMENT = "--"
+	COALESCE  = "??"
+
 	BANG  = "!"
 	EQ    = "=="
 	NOTEQ = "!="
@@ -35,6 +42,11 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
+	DOT       = "."
+
+	OPTIONAL_DOT      = "?."
+	OPTIONAL_LBRACKET = "?["
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -45,11 +57,16 @@ const (
 
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	CONST    = "CONST"
 	IF       = "IF"
 	ELSE     = "ELSE"
 	TRUE     = "TRUE"
 	FALSE    = "FALSE"
 	RETURN   = "RETURN"
+	FOR      = "FOR"
+	IN       = "IN"
+	DO       = "DO"
+	WHILE    = "WHILE"
 )
 
 func lookupIdent(ident string) TokenType {
@@ -58,6 +75,8 @@ func lookupIdent(ident string) TokenType {
 		return FUNCTION
 	case "let":
 		return LET
+	case "const":
+		return CONST
 	case "if":
 		return IF
 	case "else":
@@ -68,6 +87,14 @@ func lookupIdent(ident string) TokenType {
 		return FALSE
 	case "return":
 		return RETURN
+	case "for":
+		return FOR
+	case "in":
+		return IN
+	case "do":
+		return DO
+	case "while":
+		return WHILE
 	default:
 		return IDENT
 	}